@@ -17,7 +17,7 @@ func NewBaseProvider(info models.Account) (models.RecordProvider, error) {
 	case "Ali":
 		return ali.NewAliDNSClient(info, info.AccessKeyId, info.AccessKeySecret)
 	default:
-		return nil, nil
+		return nil, errors.New("unsupported account type: " + info.Type)
 	}
 }
 
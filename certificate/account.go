@@ -0,0 +1,242 @@
+package certificate
+
+import (
+	"DDNSServer/db"
+	"DDNSServer/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/registration"
+	"gorm.io/gorm"
+	"sync"
+)
+
+// accountPool 在多个任务之间共享的 ACME 账户池，负责邮箱轮换与申请计数
+type accountPool struct {
+	mu    sync.Mutex
+	index int                         // 当前使用的邮箱下标
+	count int                         // 当前邮箱已申请次数
+	users map[string]*models.AcmeUser // 已加载的账户，key 为 邮箱|CA
+	locks map[string]*sync.Mutex      // 账户加载锁，避免同一账户被并发注册
+}
+
+var acmeAccountPool = &accountPool{
+	users: map[string]*models.AcmeUser{},
+	locks: map[string]*sync.Mutex{},
+}
+
+// nextEmail 获取本次申请使用的邮箱，单个邮箱达到 MaxRequest 后轮换到下一个
+func (p *accountPool) nextEmail() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	emailList := models.AccountConfig.Certificate.EmailList
+	if len(emailList) == 0 {
+		return "", errors.New("未配置申请证书使用的邮箱")
+	}
+	maxRequest := models.AccountConfig.Certificate.MaxRequest
+	if p.index >= len(emailList) || (maxRequest > 0 && p.count >= maxRequest) {
+		p.index = (p.index + 1) % len(emailList)
+		p.count = 0
+	}
+	p.count++
+	return emailList[p.index], nil
+}
+
+// getLock 获取账户加载锁
+func (p *accountPool) getLock(key string) *sync.Mutex {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	lock, ok := p.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		p.locks[key] = lock
+	}
+	return lock
+}
+
+// getUser 获取已注册的 ACME 账户，优先使用内存与数据库中保存的账户，不存在时注册新账户
+func (p *accountPool) getUser(email string, options models.AcmeOptions) (*models.AcmeUser, error) {
+	key := email + "|" + options.CADirURL
+	lock := p.getLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	p.mu.Lock()
+	user, ok := p.users[key]
+	p.mu.Unlock()
+	if ok {
+		return user, nil
+	}
+
+	account, err := loadAcmeAccount(email, options.CADirURL)
+	if err != nil {
+		return nil, err
+	}
+	user = &models.AcmeUser{Email: email}
+	user.Key, err = certcrypto.ParsePEMPrivateKey([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("解析 ACME 账户私钥失败: %v", err)
+	}
+	if account.Registration != "" {
+		// 使用已注册的账户
+		var reg registration.Resource
+		if err = json.Unmarshal([]byte(account.Registration), &reg); err != nil {
+			return nil, fmt.Errorf("解析 ACME 账户注册信息失败: %v", err)
+		}
+		user.Registration = &reg
+	}
+
+	if user.Registration == nil {
+		// 注册时依次使用账户记录中保存的 EAB、申请参数中的 EAB 与配置文件中的 EAB
+		if account.EABKeyId != "" {
+			options.EABKeyId, options.EABHmacKey = account.EABKeyId, account.EABHmacKey
+		} else if options.EABKeyId == "" {
+			options.EABKeyId, options.EABHmacKey = models.ConfigEAB(options.CADirURL)
+		}
+		if err = register(user, options); err != nil {
+			return nil, err
+		}
+		regData, err := json.Marshal(user.Registration)
+		if err != nil {
+			return nil, err
+		}
+		account.Registration = string(regData)
+		account.URI = user.Registration.URI
+		account.EABKeyId, account.EABHmacKey = "", ""
+		if err = db.SaveAcmeAccount(&account); err != nil {
+			return nil, fmt.Errorf("保存 ACME 账户失败: %v", err)
+		}
+	}
+
+	p.mu.Lock()
+	p.users[key] = user
+	p.mu.Unlock()
+	return user, nil
+}
+
+// loadAcmeAccount 读取已保存的 ACME 账户，不存在时生成新的账户私钥，调用方需持有账户加载锁
+func loadAcmeAccount(email, caDirURL string) (models.AcmeAccount, error) {
+	account, err := db.GetAcmeAccount(email, caDirURL)
+	if err == nil {
+		return account, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return account, fmt.Errorf("读取 ACME 账户失败: %v", err)
+	}
+	key, err := certcrypto.GeneratePrivateKey(certcrypto.RSA2048)
+	if err != nil {
+		return account, fmt.Errorf("生成私钥失败: %v", err)
+	}
+	return models.AcmeAccount{
+		Email:      email,
+		CADirURL:   caDirURL,
+		PrivateKey: string(certcrypto.PEMEncode(key)),
+	}, nil
+}
+
+// saveEAB 将请求中提交的 EAB 保存到账户记录中，注册时使用，账户已注册时不再需要 EAB
+func (p *accountPool) saveEAB(email string, options models.AcmeOptions) error {
+	lock := p.getLock(email + "|" + options.CADirURL)
+	lock.Lock()
+	defer lock.Unlock()
+	account, err := loadAcmeAccount(email, options.CADirURL)
+	if err != nil || account.Registration != "" {
+		return err
+	}
+	account.EABKeyId, account.EABHmacKey = options.EABKeyId, options.EABHmacKey
+	if err = db.SaveAcmeAccount(&account); err != nil {
+		return fmt.Errorf("保存 ACME 账户失败: %v", err)
+	}
+	return nil
+}
+
+// PrepareAcmeAccount 在投递申请任务前确定使用的 ACME 账户
+// 请求中提交的 EAB 只保存在该账户的记录中，不写入任务负载；账户未注册且没有可用的 EAB 时返回错误
+func PrepareAcmeAccount(options *models.AcmeOptions) error {
+	if options.Email == "" {
+		email, err := acmeAccountPool.nextEmail()
+		if err != nil {
+			return err
+		}
+		options.Email = email
+	}
+	if options.EABKeyId != "" {
+		return acmeAccountPool.saveEAB(options.Email, *options)
+	}
+	account, err := db.GetAcmeAccount(options.Email, options.CADirURL)
+	if err == nil && (account.Registration != "" || account.EABKeyId != "") {
+		return nil
+	}
+	check := *options
+	check.EABKeyId, check.EABHmacKey = models.ConfigEAB(options.CADirURL)
+	return check.CheckEAB()
+}
+
+// renewAccountEmail 获取续期使用的 ACME 账户，优先使用签发证书的账户，未记录时使用在该 CA 下注册过的账户
+func renewAccountEmail(certificateInfo models.Certificate, caDirURL string) (string, error) {
+	if certificateInfo.AcmeEmail != "" {
+		return certificateInfo.AcmeEmail, nil
+	}
+	for _, email := range models.AccountConfig.Certificate.EmailList {
+		if account, err := db.GetAcmeAccount(email, caDirURL); err == nil && account.Registration != "" {
+			return email, nil
+		}
+	}
+	return acmeAccountPool.nextEmail()
+}
+
+// register 向 CA 注册账户
+func register(user *models.AcmeUser, options models.AcmeOptions) error {
+	if err := options.CheckEAB(); err != nil {
+		return err
+	}
+	config, err := options.NewLegoConfig(user)
+	if err != nil {
+		return err
+	}
+	client, err := lego.NewClient(config)
+	if err != nil {
+		return fmt.Errorf("创建 ACME 客户端失败: %v", err)
+	}
+	var reg *registration.Resource
+	if options.EABKeyId != "" {
+		reg, err = client.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
+			TermsOfServiceAgreed: true,
+			Kid:                  options.EABKeyId,
+			HmacEncoded:          options.EABHmacKey,
+		})
+	} else {
+		reg, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+	}
+	if err != nil {
+		return fmt.Errorf("注册用户失败: %v", err)
+	}
+	user.Registration = reg
+	return nil
+}
+
+// GetClient 获取申请参数指定的 ACME 账户并创建客户端，未指定时从账户池中轮换，每次轮换计为一次申请
+func GetClient(options models.AcmeOptions) (*lego.Client, error) {
+	email := options.Email
+	if email == "" {
+		var err error
+		if email, err = acmeAccountPool.nextEmail(); err != nil {
+			return nil, err
+		}
+	}
+	user, err := acmeAccountPool.getUser(email, options)
+	if err != nil {
+		return nil, err
+	}
+	config, err := options.NewLegoConfig(user)
+	if err != nil {
+		return nil, err
+	}
+	client, err := lego.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建 ACME 客户端失败: %v", err)
+	}
+	return client, nil
+}
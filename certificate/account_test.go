@@ -0,0 +1,110 @@
+package certificate
+
+import (
+	"DDNSServer/db"
+	"DDNSServer/models"
+	"encoding/json"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAccountPoolNextEmail(t *testing.T) {
+	models.AccountConfig.Certificate.EmailList = []string{"a@mail.com", "b@mail.com"}
+	models.AccountConfig.Certificate.MaxRequest = 2
+	pool := &accountPool{}
+
+	want := []string{"a@mail.com", "a@mail.com", "b@mail.com", "b@mail.com", "a@mail.com"}
+	for i, email := range want {
+		got, err := pool.nextEmail()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != email {
+			t.Errorf("第 %d 次申请使用了 %s，期望 %s", i+1, got, email)
+		}
+	}
+}
+
+func TestAccountPoolNextEmailConcurrent(t *testing.T) {
+	models.AccountConfig.Certificate.EmailList = []string{"a@mail.com", "b@mail.com", "c@mail.com"}
+	models.AccountConfig.Certificate.MaxRequest = 10
+	pool := &accountPool{}
+
+	var mu sync.Mutex
+	counts := map[string]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			email, err := pool.nextEmail()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			counts[email]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	for _, email := range models.AccountConfig.Certificate.EmailList {
+		if counts[email] != 10 {
+			t.Errorf("%s 使用了 %d 次，期望 10 次", email, counts[email])
+		}
+	}
+}
+
+func TestAccountPoolEmptyEmailList(t *testing.T) {
+	models.AccountConfig.Certificate.EmailList = nil
+	pool := &accountPool{}
+	if _, err := pool.nextEmail(); err == nil {
+		t.Error("未配置邮箱时应返回错误")
+	}
+}
+
+func TestPrepareAcmeAccount(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = database.AutoMigrate(&models.AcmeAccount{}); err != nil {
+		t.Fatal(err)
+	}
+	db.DB = database
+	models.AccountConfig.Certificate.EmailList = []string{"a@mail.com", "b@mail.com"}
+	models.AccountConfig.Certificate.CA = ""
+
+	// 必须使用 EAB 的 CA 按目录地址判断
+	options, err := models.NewAcmeOptions(models.CADirectoryList["zerossl"], "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = PrepareAcmeAccount(&options); err == nil {
+		t.Error("未注册的账户没有 EAB 时应返回错误")
+	}
+
+	// 请求中的 EAB 保存在账户记录中，不写入任务负载
+	options, _ = models.NewAcmeOptions("zerossl", "", "kid", "secret-hmac")
+	options.Email = "b@mail.com"
+	if err = PrepareAcmeAccount(&options); err != nil {
+		t.Fatal(err)
+	}
+	account, err := db.GetAcmeAccount("b@mail.com", options.CADirURL)
+	if err != nil || account.EABKeyId != "kid" || account.EABHmacKey != "secret-hmac" {
+		t.Errorf("GetAcmeAccount = %+v, %v", account, err)
+	}
+	payload, _ := json.Marshal(CreatePayload{AcmeOptions: options})
+	if strings.Contains(string(payload), "secret-hmac") {
+		t.Errorf("任务负载中包含 EAB: %s", payload)
+	}
+
+	// 续期优先使用签发证书的账户
+	if email, _ := renewAccountEmail(models.Certificate{AcmeEmail: "b@mail.com"}, options.CADirURL); email != "b@mail.com" {
+		t.Errorf("renewAccountEmail = %s, want b@mail.com", email)
+	}
+}
@@ -22,38 +22,32 @@ func getLogger(ctx context.Context) *slog.Logger {
 }
 
 // CreateCertificate 全自动申请证书
-func CreateCertificate(ctx context.Context, recordProvider models.RecordProvider, domains []models.DomainInfo) (*models.Resource, error) {
+func CreateCertificate(ctx context.Context, recordProvider models.RecordProvider, domains []models.DomainInfo, sanList []string, options models.AcmeOptions) (*models.Resource, error) {
 	logger := getLogger(ctx)
-	// 初始化 ClientManager
-	manager := models.ClientManager{}
-
 	// 获取客户端
-	client, err := manager.GetClient()
+	client, err := GetClient(options)
 	if err != nil {
 		logger.Error("创建 ACME 客户端失败", "err", err)
 		return &models.Resource{}, err
 	}
 
 	// 配置 DNS-01 挑战
-	provider := models.NewProvider(recordProvider, domains[0])
-	provider.SelfDomain = db.IsDomainExist(domains[0].DomainName)
+	provider := newChallengeProvider(recordProvider, domains)
 	err = client.Challenge.SetDNS01Provider(provider)
 	if err != nil {
 		logger.Error("设置 DNS-01 挑战失败", "err", err)
 		return &models.Resource{}, fmt.Errorf("设置 DNS-01 挑战失败: %v", err)
 	}
 
-	// 编辑域名信息
-	var domainList []string
-	for _, domain := range domains {
-		logger.Debug("编辑域名信息", "domain", domain.DomainName)
-		domainList = append(domainList, "*."+domain.DomainName)
-		domainList = append(domainList, domain.DomainName)
+	// 编辑域名信息，未指定 SAN 时申请通配符和主域名
+	domainList := sanList
+	if len(domainList) == 0 {
+		domainList = models.DefaultSanList(domains)
 	}
 
 	// 申请证书
 	request := certificate.ObtainRequest{
-		Domains: domainList, // 通配符和主域名
+		Domains: domainList,
 		Bundle:  true,
 	}
 	logger.Debug("申请证书", "domains", domainList)
@@ -63,9 +57,6 @@ func CreateCertificate(ctx context.Context, recordProvider models.RecordProvider
 		return &models.Resource{}, fmt.Errorf("申请证书失败: %v", err)
 	}
 
-	// 增加请求计数
-	manager.RequestCount++
-
 	// 保存新证书
 	logger.Debug("保存新证书", "certificate", certificates.Certificate)
 	resource, err := provider.SaveCertificate(certificates)
@@ -79,16 +70,15 @@ func CreateCertificate(ctx context.Context, recordProvider models.RecordProvider
 }
 
 // RenewCertificate 全自动续期证书
-func RenewCertificate(ctx context.Context, recordProvider models.RecordProvider, domain []models.DomainInfo, existingCert *certificate.Resource) (*models.Resource, error) {
+func RenewCertificate(ctx context.Context, recordProvider models.RecordProvider, domain []models.DomainInfo, existingCert *certificate.Resource, options models.AcmeOptions) (*models.Resource, error) {
 	logger := getLogger(ctx)
-	manager := models.ClientManager{}
-	client, err := manager.GetClient()
+	client, err := GetClient(options)
 	if err != nil {
 		return nil, err
 	}
 
 	// 配置 DNS-01 挑战
-	provider := models.NewProvider(recordProvider, domain[0])
+	provider := newChallengeProvider(recordProvider, domain)
 	err = client.Challenge.SetDNS01Provider(provider)
 	if err != nil {
 		logger.Error("设置 DNS-01 挑战失败", "err", err)
@@ -97,13 +87,15 @@ func RenewCertificate(ctx context.Context, recordProvider models.RecordProvider,
 
 	// 续期证书
 	logger.Debug("续期证书", "certificate", existingCert)
-	renewedCert, err := client.Certificate.RenewWithOptions(*existingCert, &certificate.RenewOptions{})
+	renewedCert, err := client.Certificate.RenewWithOptions(*existingCert, &certificate.RenewOptions{Bundle: true})
 	if err != nil {
 		logger.Error("续期证书失败", "err", err)
 		return nil, fmt.Errorf("续期证书失败: %v", err)
 	}
-
-	manager.RequestCount++
+	// 基于 CSR 续期时不会返回私钥，沿用原私钥
+	if renewedCert.PrivateKey == nil {
+		renewedCert.PrivateKey = existingCert.PrivateKey
+	}
 
 	// 保存新证书
 	logger.Debug("保存新证书", "certificate", renewedCert)
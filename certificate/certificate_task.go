@@ -1,10 +1,12 @@
 package certificate
 
 import (
+	"DDNSServer/DDNS"
 	"DDNSServer/db"
 	"DDNSServer/models"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/hibiken/asynq"
 	"io"
@@ -17,88 +19,198 @@ import (
 
 const TypeCertificateCreate = "certificate:create"
 
+// 任务队列名称，与 StartTaskProcessor 中的优先级配置对应
+const (
+	QueueCritical = "critical"
+	QueueDefault  = "default"
+	QueueLow      = "low"
+)
+
+var taskClient *asynq.Client
+
 type CreatePayload struct {
-	Provider       models.RecordProvider
+	AccountName    string // 申请所使用的账户名称，任务执行时据此重建 RecordProvider
 	DomainInfoList []models.DomainInfo
+	SanList        []string // 证书包含的域名，为空时申请通配符与主域名
 	TaskDataId     int
 	TaskID         string
 	LogPath        string
 	Certificate    models.Certificate
+	AcmeOptions    models.AcmeOptions
+}
+
+func getRedisOpt() asynq.RedisClientOpt {
+	return asynq.RedisClientOpt{
+		Addr: models.AccountConfig.BaseConfig.RedisPoint,
+	}
+}
+
+// InitTaskClient 初始化任务投递客户端
+func InitTaskClient() {
+	taskClient = asynq.NewClient(getRedisOpt())
 }
 
-func NewCertificateCreateTask(provider models.RecordProvider, domains []models.DomainInfo, certificateInfo models.Certificate, TaskId string) (*asynq.Task, error) {
+// GetTaskQueue 校验并返回任务队列名称，为空时使用配置中的默认队列
+func GetTaskQueue(queue string) (string, error) {
+	if queue == "" {
+		queue = models.AccountConfig.Certificate.TaskQueue
+	}
+	switch queue {
+	case "":
+		return QueueDefault, nil
+	case QueueCritical, QueueDefault, QueueLow:
+		return queue, nil
+	default:
+		return "", fmt.Errorf("未知的任务队列: %s", queue)
+	}
+}
+
+// getTaskOptions 获取证书任务的重试与超时配置
+func getTaskOptions() []asynq.Option {
+	var opts []asynq.Option
+	if models.AccountConfig.Certificate.TaskMaxRetry > 0 {
+		opts = append(opts, asynq.MaxRetry(models.AccountConfig.Certificate.TaskMaxRetry))
+	}
+	if models.AccountConfig.Certificate.TaskTimeout > 0 {
+		opts = append(opts, asynq.Timeout(time.Duration(models.AccountConfig.Certificate.TaskTimeout)*time.Minute))
+	}
+	return opts
+}
+
+// newTaskRecord 创建任务记录，logName 为日志文件名（不含扩展名）
+func newTaskRecord(taskId string, certId int, logName string) (models.CertificateTask, error) {
 	// 创建日志文件路径
 	logDir := filepath.Join(models.AccountConfig.Certificate.SavePath, "logs", time.Now().Format("2006-01-02"))
-	logPath := filepath.Join(logDir, TaskId+".log")
+	logPath := filepath.Join(logDir, logName+".log")
 	err := os.MkdirAll(logDir, 0755)
 	if err != nil {
-		slog.Log(context.Background(), slog.LevelError, "创建日志目录失败", err)
+		slog.Log(context.Background(), slog.LevelError, "创建日志目录失败", "err", err)
 	}
 	// 记录任务信息
 	taskData := models.CertificateTask{
-		TaskId:     TaskId,
+		CertId:     certId,
+		TaskId:     taskId,
 		CreateTime: time.Now(),
 		LogPath:    logPath,
 		State:      "wait",
 	}
 	err = db.DB.Create(&taskData).Error
 	if err != nil {
-		slog.Log(context.Background(), slog.LevelError, "创建任务记录失败", err)
+		slog.Log(context.Background(), slog.LevelError, "创建任务记录失败", "err", err)
+		return taskData, err
+	}
+	return taskData, nil
+}
+
+// newTaskLogger 创建同时输出到控制台与任务日志文件的日志器
+func newTaskLogger(logPath string) (*slog.Logger, *os.File, error) {
+	logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建日志文件失败: %w", err)
+	}
+	// 创建多输出日志器: 同时输出到文件和控制台
+	multiWriter := io.MultiWriter(os.Stdout, logFile)
+	// 设置slog以使用自定义输出
+	logger := slog.New(slog.NewTextHandler(multiWriter, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	}))
+	return logger, logFile, nil
+}
+
+// finishTaskRecord 根据执行结果更新任务记录状态
+func finishTaskRecord(ctx context.Context, taskDataId int, taskErr error) {
+	var taskData models.CertificateTask
+	db.DB.Model(&models.CertificateTask{}).Where("id = ?", taskDataId).First(&taskData)
+	if taskErr != nil {
+		taskData.State = "fail"
+		taskData.Result = taskErr.Error()
+	} else {
+		taskData.State = "success"
+		taskData.Result = ""
+	}
+	err := db.DB.Model(&models.CertificateTask{}).Save(&taskData).Error
+	if err != nil {
+		slog.Log(ctx, slog.LevelError, "更新任务记录失败", "err", err)
+	}
+}
+
+func NewCertificateCreateTask(accountName string, domains []models.DomainInfo, sanList []string, certificateInfo models.Certificate, options models.AcmeOptions, TaskId string) (*asynq.Task, error) {
+	taskData, err := newTaskRecord(TaskId, certificateInfo.Id, TaskId)
+	if err != nil {
 		return nil, err
 	}
 	// 创建任务负载
 	payload, err := json.Marshal(CreatePayload{
-		Provider:       provider,
+		AccountName:    accountName,
 		DomainInfoList: domains,
+		SanList:        sanList,
 		TaskDataId:     taskData.Id,
 		TaskID:         TaskId,
-		LogPath:        logPath,
+		LogPath:        taskData.LogPath,
 		Certificate:    certificateInfo,
+		AcmeOptions:    options,
 	})
 	if err != nil {
 		return nil, err
 	}
-	return asynq.NewTask(TypeCertificateCreate, payload), nil
+	return asynq.NewTask(TypeCertificateCreate, payload, getTaskOptions()...), nil
+}
+
+// EnqueueCertificateCreateTask 创建证书申请任务并投递到指定队列
+func EnqueueCertificateCreateTask(accountName string, domains []models.DomainInfo, sanList []string, certificateInfo models.Certificate, options models.AcmeOptions, TaskId string, queue string) (*asynq.TaskInfo, error) {
+	if taskClient == nil {
+		return nil, errors.New("任务客户端未初始化")
+	}
+	queue, err := GetTaskQueue(queue)
+	if err != nil {
+		return nil, err
+	}
+	task, err := NewCertificateCreateTask(accountName, domains, sanList, certificateInfo, options, TaskId)
+	if err != nil {
+		return nil, err
+	}
+	info, err := taskClient.Enqueue(task, asynq.Queue(queue))
+	if err != nil {
+		// 投递失败，任务不会被执行，直接标记为失败
+		db.DB.Model(&models.CertificateTask{}).Where("task_id = ?", TaskId).
+			Updates(models.CertificateTask{State: "fail", Result: "任务投递失败: " + err.Error()})
+		return nil, fmt.Errorf("任务投递失败: %w", err)
+	}
+	return info, nil
+}
+
+// getProviderForAccountName 根据账户名称重建 RecordProvider
+func getProviderForAccountName(accountName string) (models.RecordProvider, error) {
+	account, err := DDNS.GetAccount(accountName)
+	if err != nil {
+		return nil, err
+	}
+	return DDNS.NewBaseProvider(account)
 }
 
 func HandleCertificateCreateTask(ctx context.Context, task *asynq.Task) (err error) {
 	var p CreatePayload
 	if err = json.Unmarshal(task.Payload(), &p); err != nil {
-		err = fmt.Errorf("反序列化任务负载失败: %w", err)
+		err = fmt.Errorf("反序列化任务负载失败: %v: %w", err, asynq.SkipRetry)
 		return
 	}
 	// 更新任务状态
+	db.DB.Model(&models.CertificateTask{}).Where("id = ?", p.TaskDataId).Update("state", "apply")
+	db.DB.Model(&models.Certificate{}).Where("id = ?", p.Certificate.Id).Update("state", "apply")
 	defer func() {
-		var taskData models.CertificateTask
-		db.DB.Model(&models.CertificateTask{}).Where("id = ?", p.TaskDataId).First(&taskData)
-		if err != nil {
-			taskData.State = "fail"
-			taskData.Result = err.Error()
-		} else {
-			taskData.State = "success"
-		}
-		err := db.DB.Model(&models.CertificateTask{}).Save(&taskData).Error
 		if err != nil {
-			slog.Log(ctx, slog.LevelError, "更新任务记录失败", err)
+			db.DB.Model(&models.Certificate{}).Where("id = ?", p.Certificate.Id).Update("state", "fail")
 		}
+		finishTaskRecord(ctx, p.TaskDataId, err)
 	}()
 
 	// 初始化日志文件输出
-	logFile, err := os.OpenFile(p.LogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	logger, logFile, err := newTaskLogger(p.LogPath)
 	if err != nil {
-		err = fmt.Errorf("创建日志文件失败: %w", err)
 		return
 	}
 	defer logFile.Close()
 
-	// 创建多输出日志器: 同时输出到文件和控制台
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
-
-	// 设置slog以使用自定义输出
-	logger := slog.New(slog.NewTextHandler(multiWriter, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
-	}))
-
 	// 将logger存入context以便其他函数使用
 	ctx = context.WithValue(ctx, "logger", logger)
 
@@ -106,14 +218,27 @@ func HandleCertificateCreateTask(ctx context.Context, task *asynq.Task) (err err
 	for _, domain := range p.DomainInfoList {
 		domainNames = append(domainNames, domain.DomainName)
 	}
+	retried, _ := asynq.GetRetryCount(ctx)
 	// 记录任务开始
 	logger.Info("开始处理证书创建任务",
 		"task_id", p.TaskID,
-		"domains", len(p.DomainInfoList),
-		"provider", strings.Join(domainNames, ","))
+		"account", p.AccountName,
+		"domains", strings.Join(domainNames, ","),
+		"sanList", strings.Join(p.SanList, ","),
+		"ca", p.AcmeOptions.CADirURL,
+		"keyType", p.AcmeOptions.KeyType,
+		"retried", retried)
+
+	// 根据账户名称重建 Provider
+	provider, err := getProviderForAccountName(p.AccountName)
+	if err != nil {
+		logger.Error("获取账户失败", "error", err)
+		err = fmt.Errorf("获取账户失败: %v: %w", err, asynq.SkipRetry)
+		return
+	}
 
 	// 执行实际的证书创建
-	certData, err := CreateCertificate(ctx, p.Provider, p.DomainInfoList)
+	certData, err := CreateCertificate(ctx, provider, p.DomainInfoList, p.SanList, p.AcmeOptions)
 	if err != nil {
 		logger.Error("证书创建失败", "error", err)
 		err = fmt.Errorf("证书创建失败: %w", err)
@@ -121,13 +246,17 @@ func HandleCertificateCreateTask(ctx context.Context, task *asynq.Task) (err err
 	}
 
 	// 保存证书
-	_, err = ParseCertificateAndSaveDb(ctx, certData, &p.Certificate)
+	p.Certificate.State = "success"
+	certificateInfo, err := ParseCertificateAndSaveDb(ctx, certData, &p.Certificate)
 	if err != nil {
 		logger.Error("证书保存失败", "error", err)
 		err = fmt.Errorf("证书保存失败: %w", err)
 		return
 	}
 
+	// 部署证书
+	RunDeploys(ctx, *certificateInfo, certData)
+
 	logger.Info("证书创建任务完成",
 		"task_id", p.TaskID,
 		"domains", len(p.DomainInfoList))
@@ -135,32 +264,42 @@ func HandleCertificateCreateTask(ctx context.Context, task *asynq.Task) (err err
 	return nil
 }
 
-func StartTaskProcessor() {
-	redisOpt := asynq.RedisClientOpt{
-		Addr: models.AccountConfig.BaseConfig.RedisPoint,
+// getQueuePriority 计算队列优先级，保证每个队列至少为 1，否则 asynq 会忽略该队列
+func getQueuePriority(concurrency int, ratio float32) int {
+	priority := int(float32(concurrency) * ratio)
+	if priority < 1 {
+		priority = 1
 	}
+	return priority
+}
 
+func StartTaskProcessor() {
 	// 任务优先级分配
 	Concurrency := models.AccountConfig.Certificate.ConcurrencyTask
-	ConcurrencyCritical := int(float32(models.AccountConfig.Certificate.ConcurrencyTask) * 0.6)
-	ConcurrencyDefault := int(float32(models.AccountConfig.Certificate.ConcurrencyTask) * 0.3)
-	ConcurrencyLow := Concurrency - ConcurrencyCritical - ConcurrencyDefault
+	if Concurrency <= 0 {
+		Concurrency = 1
+	}
+	ConcurrencyCritical := getQueuePriority(Concurrency, 0.6)
+	ConcurrencyDefault := getQueuePriority(Concurrency, 0.3)
+	ConcurrencyLow := getQueuePriority(Concurrency-ConcurrencyCritical-ConcurrencyDefault, 1)
 	srv := asynq.NewServer(
-		redisOpt,
+		getRedisOpt(),
 		asynq.Config{
 			Concurrency: Concurrency, // 并发处理数
 			Queues: map[string]int{
-				"critical": ConcurrencyCritical,
-				"default":  ConcurrencyDefault,
-				"low":      ConcurrencyLow,
+				QueueCritical: ConcurrencyCritical,
+				QueueDefault:  ConcurrencyDefault,
+				QueueLow:      ConcurrencyLow,
 			},
 		},
 	)
 
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(TypeCertificateCreate, HandleCertificateCreateTask)
+	mux.HandleFunc(TypeCertificateRenewScan, HandleCertificateRenewScanTask)
+	mux.HandleFunc(TypeCertificateRenew, HandleCertificateRenewTask)
 
 	if err := srv.Run(mux); err != nil {
-		slog.Log(context.Background(), slog.LevelError, "could not run server", err)
+		slog.Log(context.Background(), slog.LevelError, "could not run server", "err", err)
 	}
 }
@@ -0,0 +1,278 @@
+package certificate
+
+import (
+	"DDNSServer/db"
+	"DDNSServer/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DeployTypeCopy    = "copy"
+	DeployTypeCommand = "command"
+	DeployTypeWebhook = "webhook"
+)
+
+const (
+	deployCommandTimeout = 5 * time.Minute
+	deployWebhookTimeout = 30 * time.Second
+	deployResultLimit    = 2000 // 保存的执行结果最大长度
+)
+
+// DeployWebhookBody 部署 webhook 请求体
+type DeployWebhookBody struct {
+	CertificateId int       `json:"certificateId"`
+	Domains       []string  `json:"domains"`
+	NotBefore     time.Time `json:"notBefore"`
+	NotAfter      time.Time `json:"notAfter"`
+	Certificate   string    `json:"certificate"`          // fullchain PEM
+	PrivateKey    string    `json:"privateKey,omitempty"` // 仅在 WithKey 时发送
+}
+
+// ValidateDeploy 校验部署目标配置
+func ValidateDeploy(deploy models.CertificateDeploy) error {
+	switch deploy.Type {
+	case DeployTypeCopy:
+		if deploy.CertPath == "" && deploy.KeyPath == "" {
+			return errors.New("证书路径与私钥路径不能同时为空")
+		}
+		if deploy.FileMode != "" {
+			if _, err := parseFileMode(deploy.FileMode, 0); err != nil {
+				return err
+			}
+		}
+	case DeployTypeCommand:
+		if !models.AccountConfig.Certificate.AllowDeployCommand {
+			return errors.New("未开启命令部署，请在配置文件中设置 AllowDeployCommand")
+		}
+		if deploy.Command == "" {
+			return errors.New("部署命令不能为空")
+		}
+	case DeployTypeWebhook:
+		if !strings.HasPrefix(deploy.WebhookUrl, "http://") && !strings.HasPrefix(deploy.WebhookUrl, "https://") {
+			return errors.New("webhook 地址格式错误")
+		}
+	default:
+		return fmt.Errorf("未知的部署类型: %s", deploy.Type)
+	}
+	return nil
+}
+
+// parseFileMode 解析八进制文件权限，为空时使用默认值
+func parseFileMode(mode string, defaultMode os.FileMode) (os.FileMode, error) {
+	if mode == "" {
+		return defaultMode, nil
+	}
+	value, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || value > 0777 {
+		return 0, fmt.Errorf("文件权限格式错误: %s", mode)
+	}
+	return os.FileMode(value), nil
+}
+
+// parseOwner 解析 user:group 或 uid:gid 形式的文件所有者，未指定的部分返回 -1
+func parseOwner(owner string) (uid int, gid int, err error) {
+	uid, gid = -1, -1
+	if owner == "" {
+		return
+	}
+	userName, groupName, _ := strings.Cut(owner, ":")
+	if userName != "" {
+		if uid, err = strconv.Atoi(userName); err != nil {
+			u, err := user.Lookup(userName)
+			if err != nil {
+				return -1, -1, fmt.Errorf("用户不存在: %s", userName)
+			}
+			uid, _ = strconv.Atoi(u.Uid)
+		}
+	}
+	if groupName != "" {
+		if gid, err = strconv.Atoi(groupName); err != nil {
+			g, err := user.LookupGroup(groupName)
+			if err != nil {
+				return -1, -1, fmt.Errorf("用户组不存在: %s", groupName)
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+	}
+	return uid, gid, nil
+}
+
+// writeDeployFile 先写入临时文件再替换目标文件，避免服务读取到不完整的证书
+func writeDeployFile(path string, data []byte, mode os.FileMode, uid, gid int) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, mode); err != nil {
+		return err
+	}
+	// WriteFile 受 umask 影响，这里重新设置权限
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if uid != -1 || gid != -1 {
+		if err := os.Chown(tmpPath, uid, gid); err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// deployCopy 复制证书与私钥到指定路径
+func deployCopy(deploy models.CertificateDeploy, resource *models.Resource) (string, error) {
+	uid, gid, err := parseOwner(deploy.Owner)
+	if err != nil {
+		return "", err
+	}
+	var result []string
+	if deploy.CertPath != "" {
+		mode, err := parseFileMode(deploy.FileMode, 0644)
+		if err != nil {
+			return "", err
+		}
+		if err = writeDeployFile(deploy.CertPath, resource.Certificate, mode, uid, gid); err != nil {
+			return "", fmt.Errorf("写入证书失败: %v", err)
+		}
+		result = append(result, "证书已写入 "+deploy.CertPath)
+	}
+	if deploy.KeyPath != "" {
+		mode, err := parseFileMode(deploy.FileMode, 0600)
+		if err != nil {
+			return "", err
+		}
+		if err = writeDeployFile(deploy.KeyPath, resource.PrivateKey, mode, uid, gid); err != nil {
+			return "", fmt.Errorf("写入私钥失败: %v", err)
+		}
+		result = append(result, "私钥已写入 "+deploy.KeyPath)
+	}
+	return strings.Join(result, "; "), nil
+}
+
+// deployCommand 执行部署命令，证书信息通过环境变量传递
+func deployCommand(ctx context.Context, deploy models.CertificateDeploy, certificateInfo models.Certificate, resource *models.Resource) (string, error) {
+	if !models.AccountConfig.Certificate.AllowDeployCommand {
+		return "", errors.New("未开启命令部署")
+	}
+	ctx, cancel := context.WithTimeout(ctx, deployCommandTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", deploy.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", deploy.Command)
+	}
+	cmd.Env = append(os.Environ(),
+		"DOMAINSPRITE_CERT_ID="+strconv.Itoa(certificateInfo.Id),
+		"DOMAINSPRITE_CERT_PATH="+resource.CertificatePath,
+		"DOMAINSPRITE_KEY_PATH="+resource.PrivateKeyPath,
+		"DOMAINSPRITE_ISSUER_PATH="+resource.IssuerCertPath,
+		"DOMAINSPRITE_DOMAINS="+certificateInfo.DNSNames,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("命令执行失败: %v", err)
+	}
+	return string(output), nil
+}
+
+// deployWebhook 将证书 POST 到 webhook
+func deployWebhook(ctx context.Context, deploy models.CertificateDeploy, certificateInfo models.Certificate, resource *models.Resource) (string, error) {
+	body := DeployWebhookBody{
+		CertificateId: certificateInfo.Id,
+		Domains:       strings.Split(certificateInfo.DNSNames, ","),
+		NotBefore:     certificateInfo.NotBefore,
+		NotAfter:      certificateInfo.NotAfter,
+		Certificate:   string(resource.Certificate),
+	}
+	if deploy.WithKey {
+		body.PrivateKey = string(resource.PrivateKey)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, deployWebhookTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, deploy.WebhookUrl, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("webhook 请求失败: %v", err)
+	}
+	defer response.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(response.Body, deployResultLimit))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return string(respBody), fmt.Errorf("webhook 返回状态码 %d", response.StatusCode)
+	}
+	return string(respBody), nil
+}
+
+// RunDeploy 执行单个部署目标并记录结果
+func RunDeploy(ctx context.Context, deploy models.CertificateDeploy, certificateInfo models.Certificate, resource *models.Resource) error {
+	var result string
+	var err error
+	switch deploy.Type {
+	case DeployTypeCopy:
+		result, err = deployCopy(deploy, resource)
+	case DeployTypeCommand:
+		result, err = deployCommand(ctx, deploy, certificateInfo, resource)
+	case DeployTypeWebhook:
+		result, err = deployWebhook(ctx, deploy, certificateInfo, resource)
+	default:
+		err = fmt.Errorf("未知的部署类型: %s", deploy.Type)
+	}
+	deploy.LastState = "success"
+	if err != nil {
+		deploy.LastState = "fail"
+		result = strings.TrimSpace(err.Error() + "\n" + result)
+	}
+	if len(result) > deployResultLimit {
+		result = result[:deployResultLimit]
+	}
+	deploy.LastResult = result
+	deploy.LastTime = time.Now()
+	if dbErr := db.UpdateCertificateDeployResult(deploy); dbErr != nil {
+		getLogger(ctx).Error("更新部署结果失败", "deploy_id", deploy.Id, "err", dbErr)
+	}
+	return err
+}
+
+// RunDeploys 执行证书的全部部署目标，部署失败不影响证书本身，只记录到任务日志中
+func RunDeploys(ctx context.Context, certificateInfo models.Certificate, resource *models.Resource) {
+	logger := getLogger(ctx)
+	deployList, err := db.GetCertificateDeployList(certificateInfo.Id)
+	if err != nil {
+		logger.Error("获取部署目标失败", "err", err)
+		return
+	}
+	for _, deploy := range deployList {
+		if !deploy.Enabled {
+			continue
+		}
+		logger.Info("开始部署证书", "deploy_id", deploy.Id, "type", deploy.Type)
+		if err = RunDeploy(ctx, deploy, certificateInfo, resource); err != nil {
+			logger.Error("证书部署失败", "deploy_id", deploy.Id, "type", deploy.Type, "err", err)
+			continue
+		}
+		logger.Info("证书部署成功", "deploy_id", deploy.Id, "type", deploy.Type)
+	}
+}
@@ -0,0 +1,202 @@
+package certificate
+
+import (
+	"DDNSServer/models"
+	"DDNSServer/utils"
+	"archive/zip"
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"os"
+	"path/filepath"
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// 证书下载格式
+const (
+	ExportCert      = "cert"      // 签发返回的证书文件
+	ExportKey       = "key"       // 私钥 PEM
+	ExportFullChain = "fullchain" // 证书 + 中间证书 PEM
+	ExportChain     = "chain"     // 中间证书 PEM
+	ExportDER       = "der"       // 证书 DER
+	ExportPFX       = "pfx"       // PKCS#12
+	ExportJKS       = "jks"       // Java KeyStore
+	ExportAll       = "all"       // 证书目录压缩包
+	ExportNginx     = "nginx"     // nginx 证书包
+	ExportApache    = "apache"    // Apache 证书包
+	ExportIIS       = "iis"       // IIS 证书包
+	ExportTomcat    = "tomcat"    // Tomcat 证书包
+)
+
+// ExportFile 导出的证书文件
+type ExportFile struct {
+	FileName string
+	Data     []byte
+}
+
+// exportMaterial 从 Resource 解析出的证书、证书链与私钥
+type exportMaterial struct {
+	leaf       *x509.Certificate
+	chain      []*x509.Certificate
+	privateKey any
+}
+
+// ExportCertificate 根据下载格式生成证书文件，pfx / jks 使用 password 加密，证书包未提供密码时随机生成并写入 password.txt
+func ExportCertificate(resource *models.Resource, format string, password string) (ExportFile, error) {
+	switch format {
+	case ExportCert:
+		return ExportFile{FileName: filepath.Base(resource.CertificatePath), Data: resource.Certificate}, nil
+	case ExportKey:
+		return ExportFile{FileName: filepath.Base(resource.PrivateKeyPath), Data: resource.PrivateKey}, nil
+	case ExportAll:
+		zipPath, err := utils.ZipFolder(resource.SavePath)
+		if err != nil {
+			return ExportFile{}, fmt.Errorf("证书压缩失败：%v", err)
+		}
+		data, err := os.ReadFile(zipPath)
+		if err != nil {
+			return ExportFile{}, fmt.Errorf("证书压缩包读取失败：%v", err)
+		}
+		return ExportFile{FileName: filepath.Base(zipPath), Data: data}, nil
+	}
+
+	material, err := parseExportMaterial(resource)
+	if err != nil {
+		return ExportFile{}, err
+	}
+	switch format {
+	case ExportFullChain:
+		return ExportFile{FileName: "fullchain.pem", Data: material.fullChainPEM()}, nil
+	case ExportChain:
+		if len(material.chain) == 0 {
+			return ExportFile{}, errors.New("证书不包含中间证书")
+		}
+		return ExportFile{FileName: "chain.pem", Data: encodeCertificatesPEM(material.chain)}, nil
+	case ExportDER:
+		return ExportFile{FileName: "certificate.der", Data: material.leaf.Raw}, nil
+	case ExportPFX:
+		data, err := pkcs12.Modern.Encode(material.privateKey, material.leaf, material.chain, password)
+		if err != nil {
+			return ExportFile{}, fmt.Errorf("PFX 生成失败：%v", err)
+		}
+		return ExportFile{FileName: "certificate.pfx", Data: data}, nil
+	case ExportJKS:
+		data, err := utils.EncodeJKS(jksAlias, material.privateKey, material.fullChain(), password)
+		if err != nil {
+			return ExportFile{}, fmt.Errorf("JKS 生成失败：%v", err)
+		}
+		return ExportFile{FileName: "keystore.jks", Data: data}, nil
+	case ExportNginx:
+		return zipExportFiles("nginx.zip", map[string][]byte{
+			"fullchain.pem": material.fullChainPEM(),
+			"privkey.pem":   resource.PrivateKey,
+		})
+	case ExportApache:
+		files := map[string][]byte{
+			"certificate.crt": encodeCertificatesPEM([]*x509.Certificate{material.leaf}),
+			"private.key":     resource.PrivateKey,
+		}
+		if len(material.chain) > 0 {
+			files["ca_bundle.crt"] = encodeCertificatesPEM(material.chain)
+		}
+		return zipExportFiles("apache.zip", files)
+	case ExportIIS:
+		password = bundlePassword(password)
+		// IIS 所在的旧版 Windows 不支持 AES 加密的 PFX，使用 3DES 保证兼容
+		data, err := pkcs12.LegacyDES.Encode(material.privateKey, material.leaf, material.chain, password)
+		if err != nil {
+			return ExportFile{}, fmt.Errorf("PFX 生成失败：%v", err)
+		}
+		return zipExportFiles("iis.zip", map[string][]byte{
+			"certificate.pfx": data,
+			"password.txt":    []byte(password),
+		})
+	case ExportTomcat:
+		password = bundlePassword(password)
+		data, err := utils.EncodeJKS(jksAlias, material.privateKey, material.fullChain(), password)
+		if err != nil {
+			return ExportFile{}, fmt.Errorf("JKS 生成失败：%v", err)
+		}
+		return zipExportFiles("tomcat.zip", map[string][]byte{
+			"keystore.jks": data,
+			"password.txt": []byte(password),
+		})
+	default:
+		return ExportFile{}, fmt.Errorf("不支持的下载类型：%s", format)
+	}
+}
+
+// jksAlias Tomcat 默认读取的密钥别名
+const jksAlias = "tomcat"
+
+// bundlePassword 证书包未指定密码时随机生成
+func bundlePassword(password string) string {
+	if password != "" {
+		return password
+	}
+	return utils.RandomToken(16)
+}
+
+// parseExportMaterial 解析证书文件中的证书链与私钥，签发证书若已包含中间证书则不再重复追加 issuer
+func parseExportMaterial(resource *models.Resource) (exportMaterial, error) {
+	var material exportMaterial
+	certificates, err := certcrypto.ParsePEMBundle(resource.Certificate)
+	if err != nil {
+		return material, fmt.Errorf("证书解析失败：%v", err)
+	}
+	material.leaf = certificates[0]
+	material.chain = certificates[1:]
+	if len(material.chain) == 0 && len(resource.IssuerCertificate) > 0 {
+		issuers, err := certcrypto.ParsePEMBundle(resource.IssuerCertificate)
+		if err != nil {
+			return material, fmt.Errorf("中间证书解析失败：%v", err)
+		}
+		material.chain = issuers
+	}
+	material.privateKey, err = certcrypto.ParsePEMPrivateKey(resource.PrivateKey)
+	if err != nil {
+		return material, fmt.Errorf("私钥解析失败：%v", err)
+	}
+	return material, nil
+}
+
+// fullChain 证书与中间证书
+func (m exportMaterial) fullChain() []*x509.Certificate {
+	return append([]*x509.Certificate{m.leaf}, m.chain...)
+}
+
+// fullChainPEM 证书与中间证书 PEM
+func (m exportMaterial) fullChainPEM() []byte {
+	return encodeCertificatesPEM(m.fullChain())
+}
+
+// encodeCertificatesPEM 将证书编码为 PEM
+func encodeCertificatesPEM(certificates []*x509.Certificate) []byte {
+	var buf bytes.Buffer
+	for _, cert := range certificates {
+		_ = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return buf.Bytes()
+}
+
+// zipExportFiles 将多个文件打包为 zip
+func zipExportFiles(fileName string, files map[string][]byte) (ExportFile, error) {
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	for name, data := range files {
+		writer, err := zipWriter.Create(name)
+		if err != nil {
+			return ExportFile{}, err
+		}
+		if _, err = writer.Write(data); err != nil {
+			return ExportFile{}, err
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		return ExportFile{}, err
+	}
+	return ExportFile{FileName: fileName, Data: buf.Bytes()}, nil
+}
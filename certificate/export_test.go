@@ -0,0 +1,153 @@
+package certificate
+
+import (
+	"DDNSServer/models"
+	"archive/zip"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"math/big"
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+	"testing"
+	"time"
+)
+
+// newTestResource 生成一个由测试 CA 签发的证书资源，证书文件包含中间证书
+func newTestResource(t *testing.T) *models.Resource {
+	t.Helper()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "www.a.com"},
+		DNSNames:     []string{"www.a.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, caCert, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resource := &models.Resource{}
+	resource.Certificate = append(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})...,
+	)
+	resource.IssuerCertificate = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	resource.PrivateKey = certcrypto.PEMEncode(leafKey)
+	return resource
+}
+
+func TestExportCertificateFormats(t *testing.T) {
+	resource := newTestResource(t)
+
+	fullChain, err := ExportCertificate(resource, ExportFullChain, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certs, _ := certcrypto.ParsePEMBundle(fullChain.Data); len(certs) != 2 {
+		t.Fatalf("fullchain 应包含 2 张证书，实际 %d", len(certs))
+	}
+
+	chain, err := ExportCertificate(resource, ExportChain, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certs, _ := certcrypto.ParsePEMBundle(chain.Data); len(certs) != 1 || certs[0].Subject.CommonName != "Test CA" {
+		t.Fatalf("chain 应只包含中间证书")
+	}
+
+	der, err := ExportCertificate(resource, ExportDER, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err := x509.ParseCertificate(der.Data); err != nil || cert.Subject.CommonName != "www.a.com" {
+		t.Fatalf("DER 证书解析失败: %v", err)
+	}
+
+	pfx, err := ExportCertificate(resource, ExportPFX, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, cert, caCerts, err := pkcs12.DecodeChain(pfx.Data, "secret"); err != nil || cert.Subject.CommonName != "www.a.com" || len(caCerts) != 1 {
+		t.Fatalf("PFX 解析失败: %v", err)
+	}
+
+	if _, err = ExportCertificate(resource, "unknown", ""); err == nil {
+		t.Fatal("不支持的格式应返回错误")
+	}
+}
+
+func TestExportCertificateJKS(t *testing.T) {
+	resource := newTestResource(t)
+	if _, err := ExportCertificate(resource, ExportJKS, ""); err == nil {
+		t.Fatal("JKS 未提供密码应返回错误")
+	}
+	jks, err := ExportCertificate(resource, ExportJKS, "changeit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := jks.Data
+	if !bytes.HasPrefix(data, []byte{0xFE, 0xED, 0xFE, 0xED, 0, 0, 0, 2, 0, 0, 0, 1}) {
+		t.Fatal("JKS 文件头错误")
+	}
+	// 校验完整性摘要
+	digest := sha1.New()
+	digest.Write([]byte{0, 'c', 0, 'h', 0, 'a', 0, 'n', 0, 'g', 0, 'e', 0, 'i', 0, 't'})
+	digest.Write([]byte("Mighty Aphrodite"))
+	digest.Write(data[:len(data)-sha1.Size])
+	if !bytes.Equal(digest.Sum(nil), data[len(data)-sha1.Size:]) {
+		t.Fatal("JKS 完整性摘要错误")
+	}
+}
+
+func TestExportCertificateBundles(t *testing.T) {
+	resource := newTestResource(t)
+	expected := map[string][]string{
+		ExportNginx:  {"fullchain.pem", "privkey.pem"},
+		ExportApache: {"certificate.crt", "ca_bundle.crt", "private.key"},
+		ExportIIS:    {"certificate.pfx", "password.txt"},
+		ExportTomcat: {"keystore.jks", "password.txt"},
+	}
+	for format, names := range expected {
+		bundle, err := ExportCertificate(resource, format, "")
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		reader, err := zip.NewReader(bytes.NewReader(bundle.Data), int64(len(bundle.Data)))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		files := map[string]bool{}
+		for _, file := range reader.File {
+			files[file.Name] = true
+		}
+		for _, name := range names {
+			if !files[name] {
+				t.Errorf("%s 证书包缺少 %s", format, name)
+			}
+		}
+	}
+}
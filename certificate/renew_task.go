@@ -0,0 +1,206 @@
+package certificate
+
+import (
+	"DDNSServer/db"
+	"DDNSServer/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TypeCertificateRenewScan = "certificate:renew-scan"
+	TypeCertificateRenew     = "certificate:renew"
+)
+
+// 默认续期配置
+const (
+	defaultRenewBeforeDays = 30
+	defaultRenewCron       = "0 3 * * *"
+)
+
+type RenewPayload struct {
+	CertificateId int
+}
+
+// StartRenewScheduler 启动证书自动续期的定时扫描
+func StartRenewScheduler() {
+	if !models.AccountConfig.Certificate.AutoRenew {
+		return
+	}
+	cronSpec := models.AccountConfig.Certificate.RenewCron
+	if cronSpec == "" {
+		cronSpec = defaultRenewCron
+	}
+	scheduler := asynq.NewScheduler(getRedisOpt(), nil)
+	_, err := scheduler.Register(cronSpec, asynq.NewTask(TypeCertificateRenewScan, nil), asynq.Queue(QueueLow))
+	if err != nil {
+		slog.Log(context.Background(), slog.LevelError, "注册续期定时任务失败", "err", err)
+		return
+	}
+	if err = scheduler.Run(); err != nil {
+		slog.Log(context.Background(), slog.LevelError, "could not run scheduler", "err", err)
+	}
+}
+
+// getRenewBefore 获取续期判断的截止时间，证书到期时间早于该时间则需要续期
+func getRenewBefore() time.Time {
+	days := models.AccountConfig.Certificate.RenewBeforeDays
+	if days <= 0 {
+		days = defaultRenewBeforeDays
+	}
+	return time.Now().AddDate(0, 0, days)
+}
+
+// HandleCertificateRenewScanTask 扫描即将到期的证书并投递续期任务
+func HandleCertificateRenewScanTask(ctx context.Context, task *asynq.Task) error {
+	certificateList, err := db.GetCertificatesToRenew(getRenewBefore())
+	if err != nil {
+		return fmt.Errorf("查询待续期证书失败: %w", err)
+	}
+	for _, certificateInfo := range certificateList {
+		_, err = EnqueueCertificateRenewTask(certificateInfo)
+		if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+			slog.Log(ctx, slog.LevelError, "投递续期任务失败", "certificateId", certificateInfo.Id, "err", err)
+		}
+	}
+	return nil
+}
+
+// EnqueueCertificateRenewTask 投递证书续期任务，同一证书每天只会投递一次
+func EnqueueCertificateRenewTask(certificateInfo models.Certificate) (*asynq.TaskInfo, error) {
+	if taskClient == nil {
+		return nil, errors.New("任务客户端未初始化")
+	}
+	payload, err := json.Marshal(RenewPayload{CertificateId: certificateInfo.Id})
+	if err != nil {
+		return nil, err
+	}
+	taskId := strings.Join([]string{TypeCertificateRenew, strconv.Itoa(certificateInfo.Id), time.Now().Format("2006-01-02")}, ":")
+	opts := append(getTaskOptions(), asynq.Queue(QueueLow), asynq.TaskID(taskId))
+	return taskClient.Enqueue(asynq.NewTask(TypeCertificateRenew, payload), opts...)
+}
+
+// getRenewDomainInfoList 获取证书申请时使用的域名信息
+func getRenewDomainInfoList(certificateInfo models.Certificate) []models.DomainInfo {
+	domainNames := strings.Split(certificateInfo.ApplyDomains, ",")
+	if certificateInfo.ApplyDomains == "" {
+		// 历史证书没有记录申请域名，从证书域名列表中还原
+		domainNames = []string{}
+		exist := map[string]bool{}
+		for _, name := range strings.Split(certificateInfo.DomainList, ",") {
+			name = strings.TrimPrefix(name, "*.")
+			if name != "" && !exist[name] {
+				exist[name] = true
+				domainNames = append(domainNames, name)
+			}
+		}
+	}
+	var domainInfoList []models.DomainInfo
+	for _, domainName := range domainNames {
+		domain, err := db.GetDomainForName(domainName)
+		if err != nil {
+			domain = models.Domains{DomainName: domainName}
+		}
+		domainInfoList = append(domainInfoList, db.DomainToDomainInfo(domain))
+	}
+	return domainInfoList
+}
+
+// HandleCertificateRenewTask 续期单个证书，每次执行都会记录一条任务记录与日志
+func HandleCertificateRenewTask(ctx context.Context, task *asynq.Task) (err error) {
+	var p RenewPayload
+	if err = json.Unmarshal(task.Payload(), &p); err != nil {
+		return fmt.Errorf("反序列化任务负载失败: %v: %w", err, asynq.SkipRetry)
+	}
+	certificateInfo, err := db.GetCertificateForId(p.CertificateId)
+	if err != nil {
+		return fmt.Errorf("查询证书失败: %v: %w", err, asynq.SkipRetry)
+	}
+	// 记录本次续期任务
+	taskData, err := newTaskRecord(certificateInfo.TaskId, certificateInfo.Id, certificateInfo.TaskId+"-renew-"+uuid.New().String()[:8])
+	if err != nil {
+		return err
+	}
+	db.DB.Model(&taskData).Update("state", "apply")
+	db.DB.Model(&certificateInfo).Update("state", "renew")
+	defer func() {
+		// 续期失败时原证书仍然有效，保持证书为成功状态
+		db.DB.Model(&models.Certificate{}).Where("id = ?", certificateInfo.Id).Update("state", "success")
+		finishTaskRecord(ctx, taskData.Id, err)
+	}()
+
+	logger, logFile, err := newTaskLogger(taskData.LogPath)
+	if err != nil {
+		return
+	}
+	defer logFile.Close()
+	ctx = context.WithValue(ctx, "logger", logger)
+
+	logger.Info("开始处理证书续期任务",
+		"certificate_id", certificateInfo.Id,
+		"account", certificateInfo.AccountName,
+		"notAfter", certificateInfo.NotAfter)
+
+	if certificateInfo.AccountName == "" {
+		err = fmt.Errorf("证书未记录申请账户，无法自动续期: %w", asynq.SkipRetry)
+		logger.Error("证书续期失败", "error", err)
+		return
+	}
+	provider, err := getProviderForAccountName(certificateInfo.AccountName)
+	if err != nil {
+		logger.Error("获取账户失败", "error", err)
+		err = fmt.Errorf("获取账户失败: %v: %w", err, asynq.SkipRetry)
+		return
+	}
+
+	// 读取原证书
+	certificatePrivate := models.CertificatePrivate{SavePath: certificateInfo.SavePath}
+	resource, err := certificatePrivate.LoadResource()
+	if err != nil {
+		logger.Error("读取原证书失败", "error", err)
+		err = fmt.Errorf("读取原证书失败: %v: %w", err, asynq.SkipRetry)
+		return
+	}
+
+	// 使用原证书的 CA、密钥类型与 ACME 账户续期
+	options, err := models.NewAcmeOptions(certificateInfo.CA, certificateInfo.KeyType, "", "")
+	if err != nil {
+		logger.Error("获取申请参数失败", "error", err)
+		err = fmt.Errorf("获取申请参数失败: %v: %w", err, asynq.SkipRetry)
+		return
+	}
+	if options.Email, err = renewAccountEmail(certificateInfo, options.CADirURL); err != nil {
+		logger.Error("获取 ACME 账户失败", "error", err)
+		err = fmt.Errorf("获取 ACME 账户失败: %v: %w", err, asynq.SkipRetry)
+		return
+	}
+	certificateInfo.AcmeEmail = options.Email
+	certData, err := RenewCertificate(ctx, provider, getRenewDomainInfoList(certificateInfo), &resource.Resource, options)
+	if err != nil {
+		logger.Error("证书续期失败", "error", err)
+		return
+	}
+
+	// 更新证书信息
+	certificateInfo.State = "success"
+	_, err = ParseCertificateAndSaveDb(ctx, certData, &certificateInfo)
+	if err != nil {
+		logger.Error("证书保存失败", "error", err)
+		err = fmt.Errorf("证书保存失败: %w", err)
+		return
+	}
+
+	// 部署证书
+	RunDeploys(ctx, certificateInfo, certData)
+
+	logger.Info("证书续期任务完成", "certificate_id", certificateInfo.Id, "notAfter", certificateInfo.NotAfter)
+	return nil
+}
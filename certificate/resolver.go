@@ -0,0 +1,80 @@
+package certificate
+
+import (
+	"DDNSServer/db"
+	"DDNSServer/models"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// zoneResolver 根据数据库中的域名记录查找验证域名所属的账户，同一次申请中复用已创建的 Provider
+type zoneResolver struct {
+	mu        sync.Mutex
+	providers map[string]models.RecordProvider
+}
+
+func newZoneResolver(providers ...models.RecordProvider) *zoneResolver {
+	resolver := &zoneResolver{providers: map[string]models.RecordProvider{}}
+	for _, provider := range providers {
+		resolver.providers[provider.GetAccountInfo().Name] = provider
+	}
+	return resolver
+}
+
+// getProvider 获取账户对应的 Provider
+func (r *zoneResolver) getProvider(accountName string) (models.RecordProvider, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if provider, ok := r.providers[accountName]; ok {
+		return provider, nil
+	}
+	provider, err := getProviderForAccountName(accountName)
+	if err != nil {
+		return nil, err
+	}
+	r.providers[accountName] = provider
+	return provider, nil
+}
+
+// Resolve 实现 models.ZoneResolver 接口
+func (r *zoneResolver) Resolve(domain string) (models.RecordProvider, models.DomainInfo, bool) {
+	zone, err := db.GetZoneForDomain(domain)
+	if err != nil || zone.AccountName == "" {
+		return nil, models.DomainInfo{}, false
+	}
+	provider, err := r.getProvider(zone.AccountName)
+	if err != nil {
+		slog.Warn("获取域名所属账户失败，使用第三方申请", "domain", domain, "account", zone.AccountName, "err", err)
+		return nil, models.DomainInfo{}, false
+	}
+	return provider, db.DomainToDomainInfo(zone), true
+}
+
+// NewExternalChallengeProvider 创建外部 ACME 客户端写入验证记录使用的 Provider，开启内置 DNS 服务时第三方域名不再需要申请账户
+func NewExternalChallengeProvider() (*models.CertificatePrivate, error) {
+	resolver := newZoneResolver()
+	var delegateProvider models.RecordProvider
+	if !models.ChallengeDNSEnabled() {
+		provider, err := resolver.getProvider(models.AccountConfig.Certificate.ApplyAccount)
+		if err != nil {
+			return nil, fmt.Errorf("获取第三方申请账户失败: %v", err)
+		}
+		delegateProvider = provider
+	}
+	return models.NewChallengeRecordProvider(delegateProvider, resolver), nil
+}
+
+// newChallengeProvider 创建 DNS-01 验证使用的 Provider，数据库中的域名使用其所属账户，其余域名使用第三方申请账户
+func newChallengeProvider(recordProvider models.RecordProvider, domains []models.DomainInfo) *models.CertificatePrivate {
+	resolver := newZoneResolver(recordProvider)
+	delegateProvider := recordProvider
+	if applyAccount := models.AccountConfig.Certificate.ApplyAccount; applyAccount != "" {
+		if provider, err := resolver.getProvider(applyAccount); err == nil {
+			delegateProvider = provider
+		}
+	}
+	provider := models.NewProvider(delegateProvider, domains)
+	provider.Resolver = resolver
+	return provider
+}
@@ -0,0 +1,111 @@
+package certificate
+
+import (
+	"DDNSServer/db"
+	"DDNSServer/models"
+	"errors"
+	"fmt"
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/lego"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RevokeReasonList 允许的吊销原因（RFC 5280 CRLReason），与 Let's Encrypt 支持的范围一致
+var RevokeReasonList = map[uint]string{
+	acme.CRLReasonUnspecified:          "unspecified",
+	acme.CRLReasonKeyCompromise:        "keyCompromise",
+	acme.CRLReasonAffiliationChanged:   "affiliationChanged",
+	acme.CRLReasonSuperseded:           "superseded",
+	acme.CRLReasonCessationOfOperation: "cessationOfOperation",
+}
+
+// RevokeCertificate 吊销证书，证书可能由账户池中的任意邮箱申请，依次使用已注册的账户尝试直到 CA 接受
+func RevokeCertificate(certificateInfo models.Certificate, reason uint) error {
+	if _, ok := RevokeReasonList[reason]; !ok {
+		return fmt.Errorf("不支持的吊销原因: %d", reason)
+	}
+	certificatePrivate := models.CertificatePrivate{SavePath: certificateInfo.SavePath}
+	resource, err := certificatePrivate.LoadResource()
+	if err != nil {
+		return fmt.Errorf("读取证书失败: %v", err)
+	}
+	options, err := models.NewAcmeOptions(certificateInfo.CA, certificateInfo.KeyType, "", "")
+	if err != nil {
+		return err
+	}
+
+	// 优先使用签发证书的账户
+	emailList := models.AccountConfig.Certificate.EmailList
+	if certificateInfo.AcmeEmail != "" {
+		emailList = append([]string{certificateInfo.AcmeEmail}, emailList...)
+	}
+	var revokeErr error
+	for i, email := range emailList {
+		if i > 0 && email == certificateInfo.AcmeEmail {
+			continue
+		}
+		// 只使用在该 CA 下注册过的账户，避免为吊销注册新账户
+		if account, err := db.GetAcmeAccount(email, options.CADirURL); err != nil || account.Id == 0 {
+			continue
+		}
+		user, err := acmeAccountPool.getUser(email, options)
+		if err != nil {
+			revokeErr = errors.Join(revokeErr, err)
+			continue
+		}
+		config, err := options.NewLegoConfig(user)
+		if err != nil {
+			return err
+		}
+		client, err := lego.NewClient(config)
+		if err != nil {
+			return fmt.Errorf("创建 ACME 客户端失败: %v", err)
+		}
+		if err = client.Certificate.RevokeWithReason(resource.Certificate, &reason); err != nil {
+			revokeErr = errors.Join(revokeErr, fmt.Errorf("%s: %v", email, err))
+			continue
+		}
+		return nil
+	}
+	if revokeErr == nil {
+		return errors.New("没有可用于吊销的 ACME 账户")
+	}
+	return fmt.Errorf("吊销证书失败: %v", revokeErr)
+}
+
+// RemoveCertificateFiles 删除证书保存目录与下载时生成的压缩包，目录被其他证书使用或不在证书保存路径下时不删除
+func RemoveCertificateFiles(certificateInfo models.Certificate) error {
+	if certificateInfo.SavePath == "" {
+		return nil
+	}
+	savePath := filepath.Clean(certificateInfo.SavePath)
+	root, err := filepath.Abs(models.AccountConfig.Certificate.SavePath)
+	if err != nil {
+		return err
+	}
+	absPath, err := filepath.Abs(savePath)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(root, absPath); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("证书目录不在证书保存路径下: %s", savePath)
+	}
+	count, err := db.CountCertificatesForSavePath(certificateInfo.SavePath, certificateInfo.Id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("证书目录仍被其他证书使用: %s", savePath)
+	}
+	if err = os.RemoveAll(savePath); err != nil {
+		return fmt.Errorf("删除证书目录失败: %v", err)
+	}
+	// 与 utils.ZipFolder 生成的路径一致
+	zipPath := filepath.Join(filepath.Dir(savePath), filepath.Base(savePath)+".zip")
+	if err = os.Remove(zipPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除证书压缩包失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package challengeDNS
+
+import (
+	"DDNSServer/models"
+	"errors"
+	"github.com/miekg/dns"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+const defaultTTL = 60
+
+// handler 委托验证域名的权威应答，只应答 SOA、NS、NS 域名地址与内存中的 TXT 验证记录
+type handler struct {
+	zone      string // 带末尾点的小写验证域名
+	ns        string // 带末尾点的小写 NS 域名
+	nsAddress net.IP
+	ttl       uint32
+}
+
+func newHandler(config models.ChallengeDNSConfig) (*handler, error) {
+	if config.Zone == "" {
+		return nil, errors.New("challengeDNS.Zone 不能为空")
+	}
+	h := &handler{
+		zone: dns.Fqdn(models.CanonicalDNSName(config.Zone)),
+		ttl:  config.TTL,
+	}
+	if h.ttl == 0 {
+		h.ttl = defaultTTL
+	}
+	h.ns = "ns." + h.zone
+	if config.NS != "" {
+		h.ns = dns.Fqdn(models.CanonicalDNSName(config.NS))
+	}
+	if config.NSAddress != "" {
+		if h.nsAddress = net.ParseIP(config.NSAddress); h.nsAddress == nil {
+			return nil, errors.New("challengeDNS.NSAddress 不是有效的 IP 地址")
+		}
+	}
+	return h, nil
+}
+
+func (h *handler) header(name string, rrType uint16) dns.RR_Header {
+	return dns.RR_Header{Name: name, Rrtype: rrType, Class: dns.ClassINET, Ttl: h.ttl}
+}
+
+func (h *handler) soa() dns.RR {
+	return &dns.SOA{
+		Hdr:     h.header(h.zone, dns.TypeSOA),
+		Ns:      h.ns,
+		Mbox:    "hostmaster." + h.zone,
+		Serial:  uint32(time.Now().Unix()),
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  h.ttl,
+	}
+}
+
+// answer 查询记录，第二个返回值表示该名称是否存在
+func (h *handler) answer(name string, qType uint16) ([]dns.RR, bool) {
+	var answers []dns.RR
+	exists := false
+	if name == h.zone {
+		exists = true
+		switch qType {
+		case dns.TypeSOA:
+			answers = append(answers, h.soa())
+		case dns.TypeNS:
+			answers = append(answers, &dns.NS{Hdr: h.header(h.zone, dns.TypeNS), Ns: h.ns})
+		}
+	}
+	if name == h.ns && h.nsAddress != nil {
+		exists = true
+		if ip4 := h.nsAddress.To4(); ip4 != nil && qType == dns.TypeA {
+			answers = append(answers, &dns.A{Hdr: h.header(name, dns.TypeA), A: ip4})
+		} else if ip4 == nil && qType == dns.TypeAAAA {
+			answers = append(answers, &dns.AAAA{Hdr: h.header(name, dns.TypeAAAA), AAAA: h.nsAddress})
+		}
+	}
+	if values := models.ChallengeTXT.Get(name); len(values) > 0 {
+		exists = true
+		if qType == dns.TypeTXT {
+			for _, value := range values {
+				// 验证记录使用最短 TTL，避免重试时递归服务器缓存旧值
+				txt := &dns.TXT{Hdr: h.header(name, dns.TypeTXT), Txt: []string{value}}
+				txt.Hdr.Ttl = 1
+				answers = append(answers, txt)
+			}
+		}
+	}
+	return answers, exists
+}
+
+// ServeDNS 实现 dns.Handler 接口
+func (h *handler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	if len(r.Question) != 1 {
+		m.SetRcode(r, dns.RcodeFormatError)
+		_ = w.WriteMsg(m)
+		return
+	}
+	question := r.Question[0]
+	name := strings.ToLower(question.Name)
+	if name != h.zone && !strings.HasSuffix(name, "."+h.zone) {
+		m.SetRcode(r, dns.RcodeRefused)
+		_ = w.WriteMsg(m)
+		return
+	}
+	m.Authoritative = true
+	answers, exists := h.answer(name, question.Qtype)
+	m.Answer = answers
+	if !exists {
+		m.Rcode = dns.RcodeNameError
+	}
+	if len(answers) == 0 {
+		m.Ns = append(m.Ns, h.soa())
+	}
+	_ = w.WriteMsg(m)
+}
+
+// Start 启动内置 DNS 服务，同时监听 UDP 与 TCP
+func Start() {
+	config := models.AccountConfig.ChallengeDNS
+	if !config.Enable {
+		return
+	}
+	h, err := newHandler(config)
+	if err != nil {
+		slog.Error("内置 DNS 服务配置错误", "err", err)
+		return
+	}
+	listen := config.Listen
+	if listen == "" {
+		listen = ":53"
+	}
+	for _, network := range []string{"udp", "tcp"} {
+		server := &dns.Server{Addr: listen, Net: network, Handler: h}
+		go func() {
+			slog.Info("内置 DNS 服务已启动", "zone", h.zone, "listen", listen, "net", server.Net)
+			if err := server.ListenAndServe(); err != nil {
+				slog.Error("内置 DNS 服务启动失败", "net", server.Net, "err", err)
+			}
+		}()
+	}
+}
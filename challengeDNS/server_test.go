@@ -0,0 +1,75 @@
+package challengeDNS
+
+import (
+	"DDNSServer/models"
+	"github.com/miekg/dns"
+	"net"
+	"testing"
+)
+
+// startTestServer 在本地随机端口启动 DNS 服务
+func startTestServer(t *testing.T) string {
+	t.Helper()
+	h, err := newHandler(models.ChallengeDNSConfig{Zone: "acme.a.com", NS: "ns.a.com", NSAddress: "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	server := &dns.Server{PacketConn: conn, Handler: h, NotifyStartedFunc: func() { close(started) }}
+	go func() { _ = server.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return conn.LocalAddr().String()
+}
+
+func query(t *testing.T, addr, name string, qType uint16) *dns.Msg {
+	t.Helper()
+	m := new(dns.Msg)
+	m.SetQuestion(name, qType)
+	r, err := dns.Exchange(m, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestServeChallengeTXT(t *testing.T) {
+	addr := startTestServer(t)
+	name := "abc.acme.a.com"
+	models.ChallengeTXT.Add(name, "value1")
+	models.ChallengeTXT.Add(name, "value2")
+	defer models.ChallengeTXT.Remove(name, "value1")
+	defer models.ChallengeTXT.Remove(name, "value2")
+
+	r := query(t, addr, "ABC.acme.a.com.", dns.TypeTXT)
+	if r.Rcode != dns.RcodeSuccess || !r.Authoritative || len(r.Answer) != 2 {
+		t.Fatalf("TXT 应答错误: %v", r)
+	}
+
+	models.ChallengeTXT.Remove(name, "value1")
+	r = query(t, addr, "abc.acme.a.com.", dns.TypeTXT)
+	if len(r.Answer) != 1 || r.Answer[0].(*dns.TXT).Txt[0] != "value2" {
+		t.Fatalf("删除后 TXT 应答错误: %v", r)
+	}
+}
+
+func TestServeZoneRecords(t *testing.T) {
+	addr := startTestServer(t)
+
+	if r := query(t, addr, "acme.a.com.", dns.TypeSOA); len(r.Answer) != 1 {
+		t.Fatalf("SOA 应答错误: %v", r)
+	}
+	if r := query(t, addr, "acme.a.com.", dns.TypeNS); len(r.Answer) != 1 || r.Answer[0].(*dns.NS).Ns != "ns.a.com." {
+		t.Fatalf("NS 应答错误: %v", r)
+	}
+	if r := query(t, addr, "missing.acme.a.com.", dns.TypeTXT); r.Rcode != dns.RcodeNameError || len(r.Ns) != 1 {
+		t.Fatalf("不存在的记录应返回 NXDOMAIN: %v", r)
+	}
+	if r := query(t, addr, "www.b.com.", dns.TypeA); r.Rcode != dns.RcodeRefused {
+		t.Fatalf("非委托域名应拒绝: %v", r)
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"DDNSServer/models"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// detectAddress 按配置获取指定协议栈（tcp4 / tcp6）的公网地址
+func detectAddress(config models.ClientConfig, network string) (string, error) {
+	mode := config.IPv4
+	if network == "tcp6" {
+		mode = config.IPv6
+	}
+	if mode == "interface" {
+		return interfaceAddress(config.Interface, network)
+	}
+	return echoAddress(config.EchoUrl, network)
+}
+
+// echoAddress 通过指定协议栈连接 echo 地址，获取服务端看到的来源 IP
+func echoAddress(echoUrl, network string) (string, error) {
+	dialer := &net.Dialer{Timeout: requestTimeout}
+	httpClient := &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			// 不使用代理，否则 echo 服务看到的是代理的地址
+			Proxy: nil,
+			DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+	}
+	response, err := httpClient.Get(echoUrl)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, 256))
+	if err != nil {
+		return "", err
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil || (ip.To4() != nil) != (network == "tcp4") {
+		return "", errors.New("echo 地址返回的不是有效的 IP: " + strings.TrimSpace(string(body)))
+	}
+	return ip.String(), nil
+}
+
+// interfaceAddress 从本机网卡获取第一个公网地址，name 为空时查找所有网卡
+func interfaceAddress(name, network string) (string, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+	for _, iface := range interfaces {
+		if (name != "" && iface.Name != name) || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if ip := ipNet.IP; isPublicIP(ip) && (ip.To4() != nil) == (network == "tcp4") {
+				return ip.String(), nil
+			}
+		}
+	}
+	return "", errors.New("未找到公网地址")
+}
+
+// isPublicIP 判断是否为公网地址，排除内网、回环、链路本地与 IPv6 ULA 地址
+func isPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
@@ -0,0 +1,200 @@
+package client
+
+import (
+	"DDNSServer/models"
+	"DDNSServer/models/requestModel"
+	"DDNSServer/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultInterval   = 300 // 默认检查周期（秒）
+	defaultForceHours = 24  // 默认强制上报周期（小时）
+	requestTimeout    = 30 * time.Second
+)
+
+// state 本地状态缓存，记录上次成功上报的地址
+type state struct {
+	IPv4       string    `json:"ipv4"`
+	IPv6       string    `json:"ipv6"`
+	UpdateTime time.Time `json:"updateTime"`
+}
+
+// Run 运行 DDNS 客户端，-c 指定配置文件，-once 只检查一次后退出（适用于 cron）
+func Run(args []string, example string) error {
+	flags := flag.NewFlagSet("client", flag.ContinueOnError)
+	configPath := flags.String("c", "client.toml", "配置文件路径")
+	once := flags.Bool("once", false, "只检查一次后退出")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if utils.InitConfigFile(*configPath, example) {
+		return nil
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if *once {
+		return check(config)
+	}
+	slog.Info("DDNS 客户端已启动", "server", config.Server, "interval", config.Interval)
+	ticker := time.NewTicker(time.Duration(config.Interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		if err = check(config); err != nil {
+			slog.Error("DDNS 客户端更新失败", "err", err)
+		}
+		<-ticker.C
+	}
+}
+
+// loadConfig 读取客户端配置并补充默认值
+func loadConfig(configPath string) (models.ClientConfig, error) {
+	var config models.ClientConfig
+	if _, err := toml.DecodeFile(configPath, &config); err != nil {
+		return config, err
+	}
+	if config.Server == "" || config.Token == "" {
+		return config, errors.New("Server 与 Token 不能为空")
+	}
+	if config.IPv4 == "" {
+		config.IPv4 = "echo"
+	}
+	if config.IPv6 == "" {
+		config.IPv6 = "off"
+	}
+	for _, mode := range []string{config.IPv4, config.IPv6} {
+		if mode != "echo" && mode != "interface" && mode != "off" {
+			return config, fmt.Errorf("未知的地址获取方式: %s", mode)
+		}
+		if mode == "echo" && config.EchoUrl == "" {
+			return config, errors.New("使用 echo 获取地址时 EchoUrl 不能为空")
+		}
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.ForceHours <= 0 {
+		config.ForceHours = defaultForceHours
+	}
+	if config.StatePath == "" {
+		config.StatePath = "client-state.json"
+	}
+	return config, nil
+}
+
+// check 获取当前地址，与上次上报的地址不同或超过强制上报周期时调用服务端更新
+func check(config models.ClientConfig) error {
+	current := state{}
+	var errs []error
+	var err error
+	if config.IPv4 != "off" {
+		if current.IPv4, err = detectAddress(config, "tcp4"); err != nil {
+			errs = append(errs, fmt.Errorf("获取 IPv4 地址失败: %w", err))
+		}
+	}
+	if config.IPv6 != "off" {
+		if current.IPv6, err = detectAddress(config, "tcp6"); err != nil {
+			errs = append(errs, fmt.Errorf("获取 IPv6 地址失败: %w", err))
+		}
+	}
+	// 其中一个协议栈获取失败时仍然更新另一个
+	if current.IPv4 == "" && current.IPv6 == "" {
+		return errors.Join(errs...)
+	}
+	for _, err = range errs {
+		slog.Warn("DDNS 客户端获取地址失败", "err", err)
+	}
+	last := loadState(config.StatePath)
+	if !needUpdate(last, current, time.Now(), config.ForceHours) {
+		return nil
+	}
+	if err = update(config, current); err != nil {
+		return err
+	}
+	slog.Info("DDNS 客户端已更新地址", "ipv4", current.IPv4, "ipv6", current.IPv6)
+	// 获取失败的协议栈保留上次的地址
+	if current.IPv4 == "" {
+		current.IPv4 = last.IPv4
+	}
+	if current.IPv6 == "" {
+		current.IPv6 = last.IPv6
+	}
+	current.UpdateTime = time.Now()
+	return saveState(config.StatePath, current)
+}
+
+// needUpdate 判断是否需要调用服务端，获取失败的协议栈不参与比较
+func needUpdate(last, current state, now time.Time, forceHours int) bool {
+	if now.Sub(last.UpdateTime) >= time.Duration(forceHours)*time.Hour {
+		return true
+	}
+	return (current.IPv4 != "" && current.IPv4 != last.IPv4) || (current.IPv6 != "" && current.IPv6 != last.IPv6)
+}
+
+// update 调用服务端 /fast/updateRecord 更新地址
+func update(config models.ClientConfig, current state) error {
+	updateUrl := strings.TrimSuffix(config.Server, "/") + "/fast"
+	if config.Pool != "" {
+		updateUrl += "/" + url.PathEscape(config.Pool)
+	}
+	query := url.Values{"token": {config.Token}}
+	if current.IPv4 != "" {
+		query.Set("ipv4", current.IPv4)
+	}
+	if current.IPv6 != "" {
+		query.Set("ipv6", current.IPv6)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, updateUrl+"/updateRecord?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("User-Agent", "DomainSprite-Client")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+	var result requestModel.Response
+	if err = json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("服务端返回状态码 %d: %s", response.StatusCode, body)
+	}
+	if result.Code != requestModel.SuccessCode {
+		return fmt.Errorf("服务端返回错误: %s", result.Message)
+	}
+	return nil
+}
+
+// loadState 读取本地状态，文件不存在或损坏时返回空状态
+func loadState(statePath string) state {
+	var last state
+	if data, err := os.ReadFile(statePath); err == nil {
+		_ = json.Unmarshal(data, &last)
+	}
+	return last
+}
+
+// saveState 保存本地状态
+func saveState(statePath string, current state) error {
+	data, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(statePath, data, 0644)
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"DDNSServer/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNeedUpdate(t *testing.T) {
+	now := time.Now()
+	last := state{IPv4: "192.0.2.1", IPv6: "2001:db8::1", UpdateTime: now.Add(-time.Hour)}
+	for _, test := range []struct {
+		name    string
+		current state
+		want    bool
+	}{
+		{"地址未变化", state{IPv4: "192.0.2.1", IPv6: "2001:db8::1"}, false},
+		{"IPv4 变化", state{IPv4: "192.0.2.2", IPv6: "2001:db8::1"}, true},
+		{"IPv6 获取失败", state{IPv4: "192.0.2.1"}, false},
+	} {
+		if got := needUpdate(last, test.current, now, 24); got != test.want {
+			t.Errorf("%s: needUpdate = %v, want %v", test.name, got, test.want)
+		}
+	}
+	if !needUpdate(last, last, now.Add(24*time.Hour), 24) {
+		t.Error("超过强制上报周期时应更新")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	var path, query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.RawQuery
+		if r.URL.Query().Get("token") != "t1" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code": 400, "message": "Token Not Exist"}`))
+			return
+		}
+		w.Write([]byte(`{"code": 200, "message": "Success"}`))
+	}))
+	defer server.Close()
+	config := models.ClientConfig{Server: server.URL + "/", Token: "t1", Pool: "lab"}
+	if err := update(config, state{IPv4: "192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/fast/lab/updateRecord" || query != "ipv4=192.0.2.1&token=t1" {
+		t.Errorf("request = %s?%s", path, query)
+	}
+	config.Token = "t2"
+	if err := update(config, state{IPv4: "192.0.2.1"}); err == nil {
+		t.Error("服务端返回错误时应返回错误")
+	}
+}
@@ -2,23 +2,129 @@ package db
 
 import (
 	"DDNSServer/models"
+	"errors"
 	"github.com/glebarez/sqlite" // 替换为新的 SQLite 驱动
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log/slog"
+	"os"
+	"time"
 )
 
+// fastDataFile 旧版快速解析数据文件
+const fastDataFile = "fastData.json"
+
 var DB = &gorm.DB{}
 
 func InitDB() error {
-	// 连接 SQLite 数据库
-	db, err := gorm.Open(sqlite.Open("database.db"), &gorm.Config{})
+	// 连接 SQLite 数据库，并发写入时等待锁释放而不是直接返回 database is locked
+	db, err := gorm.Open(sqlite.Open("database.db?_pragma=busy_timeout(5000)"), &gorm.Config{})
 	if err != nil {
 		return err
 	}
 	// 自动迁移（创建/更新表结构）
-	err = db.AutoMigrate(&models.Domains{}, &models.Certificate{}, &models.CertificateTask{})
+	err = db.AutoMigrate(&models.Domains{}, &models.Certificate{}, &models.CertificateTask{}, &models.AcmeAccount{}, &models.CertificateDeploy{}, &models.CertificatePullToken{}, &models.ChallengeClient{}, &models.DDNSClient{}, &models.FastData{}, &models.FastRecord{}, &models.FastSequence{}, &models.FastHistory{})
 	if err != nil {
 		return err
 	}
+	if err = migrateFastData(db); err != nil {
+		return err
+	}
+	// 导入旧版快速解析数据
+	if err = importFastDataJson(db, models.AccountConfig.FastConfig.DataPath+fastDataFile); err != nil {
+		return err
+	}
 	DB = db
 	return nil
 }
+
+// migrateFastData 迁移旧版快速解析表：明文 Token 改为保存哈希，主机记录改为在主域名内唯一，并补充默认域名
+func migrateFastData(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if migrator.HasColumn(&models.FastData{}, "token") {
+		var rows []struct {
+			Id    int
+			Token string
+		}
+		if err := db.Table("fast_data").Select("id, token").Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			fastData := models.FastData{Id: row.Id, Token: row.Token}
+			if err := fastData.BeforeSave(db); err != nil {
+				return err
+			}
+			if err := db.Model(&fastData).UpdateColumns(map[string]interface{}{
+				"token_hash":   fastData.TokenHash,
+				"token_prefix": fastData.TokenPrefix,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if migrator.HasIndex(&models.FastData{}, "idx_fast_data_token") {
+			if err := migrator.DropIndex(&models.FastData{}, "idx_fast_data_token"); err != nil {
+				return err
+			}
+		}
+		if err := migrator.DropColumn(&models.FastData{}, "token"); err != nil {
+			return err
+		}
+		slog.Info("已将快速解析 Token 迁移为哈希保存", "count", len(rows))
+	}
+	if migrator.HasIndex(&models.FastData{}, "idx_fast_data_record_name") {
+		if err := migrator.DropIndex(&models.FastData{}, "idx_fast_data_record_name"); err != nil {
+			return err
+		}
+	}
+	fastConfig := models.AccountConfig.FastConfig
+	if fastConfig.DomainName == "" {
+		return nil
+	}
+	return db.Model(&models.FastData{}).Where("domain_name IS NULL OR domain_name = ''").UpdateColumns(map[string]interface{}{
+		"account_name": fastConfig.UseAccount,
+		"domain_id":    fastConfig.DomainId,
+		"domain_name":  fastConfig.DomainName,
+	}).Error
+}
+
+// importFastDataJson 将旧版 fastData.json 中的快速解析记录导入数据库，只执行一次
+// 原文件中保存的是明文 Token，导入后删除，旧版本导入后保留的 .imported 文件同样删除
+func importFastDataJson(db *gorm.DB, filePath string) error {
+	if err := os.Remove(filePath + ".imported"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if _, err := os.Stat(filePath); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	var fastDataJson models.FastDataJson
+	if err := fastDataJson.LoadFromJson(filePath); err != nil {
+		return err
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		fastConfig := models.AccountConfig.FastConfig
+		for _, fastData := range fastDataJson.DataList {
+			if fastData.Token == "" || fastData.RecordName == "" {
+				continue
+			}
+			fastData.AccountName, fastData.DomainId, fastData.DomainName = fastConfig.UseAccount, fastConfig.DomainId, fastConfig.DomainName
+			fastData.CreateTime = time.Now()
+			// Token 或主机记录已存在时跳过
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&fastData).Error; err != nil {
+				return err
+			}
+		}
+		sequence, err := getFastSequence(tx, fastConfig)
+		if err != nil {
+			return err
+		}
+		if fastDataJson.LastId > sequence.LastId {
+			sequence.LastId = fastDataJson.LastId
+		}
+		return tx.Save(&sequence).Error
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("已导入旧版快速解析数据", "file", filePath, "count", len(fastDataJson.DataList))
+	return os.Remove(filePath)
+}
@@ -0,0 +1,278 @@
+package db
+
+import (
+	"DDNSServer/models"
+	"DDNSServer/utils"
+	"errors"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")+"?_pragma=busy_timeout(5000)"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&models.FastData{}, &models.FastRecord{}, &models.FastSequence{}, &models.FastHistory{}); err != nil {
+		t.Fatal(err)
+	}
+	DB = db
+	return db
+}
+
+func TestNextFastId(t *testing.T) {
+	openTestDB(t)
+	pool := models.FastConfig{StartId: 5, EndId: 7}
+	for want := 5; want <= 7; want++ {
+		id, err := NextFastId(pool)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != want {
+			t.Errorf("NextFastId() = %d, want %d", id, want)
+		}
+	}
+	if _, err := NextFastId(pool); err == nil {
+		t.Error("超过结束编号应返回错误")
+	}
+	// 不同解析池的编号互不影响
+	if id, _ := NextFastId(models.FastConfig{Name: "lab", StartId: 100}); id != 100 {
+		t.Errorf("NextFastId(lab) = %d, want 100", id)
+	}
+	// 并发分配的编号不重复
+	var wg sync.WaitGroup
+	ids := make([]int, 20)
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := NextFastId(models.FastConfig{Name: "concurrent", StartId: 1})
+			if err != nil {
+				t.Error(err)
+			}
+			ids[i] = id
+		}()
+	}
+	wg.Wait()
+	seen := map[int]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			t.Errorf("NextFastId() 并发分配了重复的编号 %d", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestImportFastDataJson(t *testing.T) {
+	db := openTestDB(t)
+	filePath := filepath.Join(t.TempDir(), fastDataFile)
+	data := `{"lastId": 12, "dataList": [
+		{"token": "t1", "recordInfo": {"recordName": "ddns001", "recordType": "A", "recordContent": "192.0.2.1"}},
+		{"token": "t1", "recordInfo": {"recordName": "ddns002", "recordType": "A", "recordContent": "192.0.2.2"}}
+	]}`
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 旧版本导入后保留的文件
+	if err := os.WriteFile(filePath+".imported", []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := importFastDataJson(db, filePath); err != nil {
+		t.Fatal(err)
+	}
+	// 保存明文 Token 的文件导入后删除
+	for _, path := range []string{filePath, filePath + ".imported"} {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("导入后应删除 %s: %v", path, err)
+		}
+	}
+	// 重复的 Token 跳过
+	var count int64
+	db.Model(&models.FastData{}).Count(&count)
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+	fastData, err := GetFastDataForIp("", "192.0.2.1")
+	if err != nil || fastData.TokenHash != utils.HashToken("t1") || fastData.RecordName != "ddns001" {
+		t.Errorf("GetFastDataForIp = %+v, %v", fastData, err)
+	}
+	if id, _ := NextFastId(models.FastConfig{}); id != 12 {
+		t.Errorf("NextFastId() = %d, want 12", id)
+	}
+	// 文件已删除，再次导入不做任何操作
+	if err = importFastDataJson(db, filePath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateFastData(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	DB = db
+	// 旧版表结构，明文保存 Token
+	type fastData struct {
+		Id         int    `gorm:"primaryKey"`
+		Token      string `gorm:"not null;uniqueIndex"`
+		RecordName string `gorm:"not null;uniqueIndex"`
+	}
+	if err = db.Table("fast_data").AutoMigrate(&fastData{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Table("fast_data").Create(&fastData{Token: "old-token", RecordName: "ddns001"})
+	if err = db.AutoMigrate(&models.FastData{}, &models.FastRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	models.AccountConfig.FastConfig.UseAccount = "account1"
+	models.AccountConfig.FastConfig.DomainName = "a.com"
+	defer func() { models.AccountConfig.FastConfig = models.FastConfig{} }()
+	if err = migrateFastData(db); err != nil {
+		t.Fatal(err)
+	}
+	if db.Migrator().HasColumn(&models.FastData{}, "token") {
+		t.Error("迁移后应删除明文 Token")
+	}
+	data, err := GetFastDataForToken("old-token")
+	if err != nil || data.DomainName != "a.com" || data.AccountName != "account1" || data.TokenPrefix != "old-toke" {
+		t.Errorf("GetFastDataForToken = %+v, %v", data, err)
+	}
+	// 主机记录只在同一主域名内唯一
+	other := models.FastData{Token: "new-token", DomainName: "b.com", RecordName: "ddns001"}
+	if err = db.Create(&other).Error; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetFastHistoryList(t *testing.T) {
+	openTestDB(t)
+	for _, history := range []models.FastHistory{
+		{FastId: 1, FQDN: "ddns001.a.com", OldIP: "192.0.2.1", NewIP: "192.0.2.2"},
+		{FastId: 2, FQDN: "ddns002.a.com", NewIP: "192.0.2.3"},
+		{FastId: 1, FQDN: "ddns001.a.com", OldIP: "192.0.2.2", NewIP: "192.0.2.4"},
+	} {
+		if err := CreateFastHistory(&history); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, total, err := GetFastHistoryList(1, "", 1, 10)
+	if err != nil || total != 2 || list[0].NewIP != "192.0.2.4" {
+		t.Errorf("GetFastHistoryList(1) = %+v, %d, %v", list, total, err)
+	}
+	if _, total, _ = GetFastHistoryList(0, "192.0.2.2", 1, 10); total != 2 {
+		t.Errorf("GetFastHistoryList(keyWord) total = %d, want 2", total)
+	}
+}
+
+func TestGetFastDataForIp(t *testing.T) {
+	openTestDB(t)
+	// 同一地址在不同解析池中各自登记
+	for _, pool := range []string{"", "lab"} {
+		fastData := models.FastData{Pool: pool, Token: "t-" + pool, DomainName: "a.com", RecordName: "box-" + pool}
+		fastData.RecordInfo.RecordContent = "192.0.2.1"
+		if err := SaveFastData(&fastData); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, pool := range []string{"", "lab"} {
+		fastData, err := GetFastDataForIp(pool, "192.0.2.1")
+		if err != nil || fastData.Pool != pool || fastData.RecordName != "box-"+pool {
+			t.Errorf("GetFastDataForIp(%q) = %+v, %v", pool, fastData, err)
+		}
+	}
+	if _, err := GetFastDataForIp("other", "192.0.2.1"); err == nil {
+		t.Error("不应查询到其他解析池的记录")
+	}
+}
+
+func TestGetFastDataInactiveSince(t *testing.T) {
+	openTestDB(t)
+	now := time.Now()
+	for i, fastData := range []models.FastData{
+		{RecordName: "stale", LastSeenTime: now.Add(-48 * time.Hour), CreateTime: now.Add(-72 * time.Hour)},
+		{RecordName: "never", CreateTime: now.Add(-48 * time.Hour)},
+		{RecordName: "active", LastSeenTime: now.Add(-time.Hour), CreateTime: now.Add(-72 * time.Hour)},
+		{RecordName: "new", CreateTime: now},
+	} {
+		fastData.Token, fastData.DomainName = strconv.Itoa(i), "a.com"
+		// 保存时会更新 update_time，相当于管理员修改了记录
+		if err := SaveFastData(&fastData); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fastDataList, err := GetFastDataInactiveSince(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fastData := range fastDataList {
+		names = append(names, fastData.RecordName)
+	}
+	if !reflect.DeepEqual(names, []string{"stale", "never"}) {
+		t.Errorf("GetFastDataInactiveSince = %v, want [stale never]", names)
+	}
+}
+
+func TestSaveFastDataRecords(t *testing.T) {
+	openTestDB(t)
+	fastData := models.FastData{Token: "t1", DomainName: "a.com", RecordName: "box1"}
+	fastData.Records = []models.FastRecord{{DomainName: "b.com", RecordName: "*.box1"}}
+	if err := SaveFastData(&fastData); err != nil {
+		t.Fatal(err)
+	}
+	data, err := GetFastDataForToken("t1")
+	if err != nil || len(data.Records) != 1 || data.Records[0].FQDN() != "*.box1.b.com" {
+		t.Fatalf("GetFastDataForToken = %+v, %v", data, err)
+	}
+	if !IsFastRecordNameExist("b.com", "*.box1") {
+		t.Error("其他主机记录同样视为已使用")
+	}
+	data.Records[0].RecordInfo.RecordContent = "192.0.2.1"
+	if err = SaveFastData(&data); err != nil {
+		t.Fatal(err)
+	}
+	if record, _ := GetFastRecordForId(data.Records[0].Id); record.RecordInfo.RecordContent != "192.0.2.1" {
+		t.Errorf("RecordInfo = %+v", record.RecordInfo)
+	}
+	if err = DeleteFastData(data.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = GetFastRecordForId(data.Records[0].Id); err == nil {
+		t.Error("删除 Token 时应同时删除其他主机记录")
+	}
+}
+
+func TestCreateFastRecordNameExist(t *testing.T) {
+	openTestDB(t)
+	fastData := models.FastData{Token: "t1", DomainName: "a.com", RecordName: "box1"}
+	if err := CreateFastData(&fastData); err != nil {
+		t.Fatal(err)
+	}
+	record := models.FastRecord{FastId: fastData.Id, DomainName: "a.com", RecordName: "box1"}
+	if err := CreateFastRecord(&record); !errors.Is(err, ErrFastRecordNameExist) {
+		t.Errorf("CreateFastRecord = %v, want ErrFastRecordNameExist", err)
+	}
+	record = models.FastRecord{FastId: fastData.Id, DomainName: "a.com", RecordName: "box2"}
+	if err := CreateFastRecord(&record); err != nil {
+		t.Fatal(err)
+	}
+	other := models.FastData{Token: "t2", DomainName: "a.com", RecordName: "box2"}
+	if err := CreateFastData(&other); !errors.Is(err, ErrFastRecordNameExist) {
+		t.Errorf("CreateFastData = %v, want ErrFastRecordNameExist", err)
+	}
+	// 失败的事务不应留下记录
+	var fastCount, recordCount int64
+	DB.Model(&models.FastData{}).Count(&fastCount)
+	DB.Model(&models.FastRecord{}).Count(&recordCount)
+	if fastCount != 1 || recordCount != 1 {
+		t.Errorf("FastData = %d, FastRecord = %d, want 1, 1", fastCount, recordCount)
+	}
+}
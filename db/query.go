@@ -2,7 +2,12 @@ package db
 
 import (
 	"DDNSServer/models"
+	"DDNSServer/utils"
 	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
 )
 
 func mapDomainFields(domainInfo models.DomainInfo, domain models.Domains) (models.Domains, models.DomainInfo) {
@@ -53,6 +58,37 @@ func IsDomainExist(domainName string) bool {
 	return false
 }
 
+// GetDomainForName 根据域名获取域名信息
+func GetDomainForName(domainName string) (models.Domains, error) {
+	if domainName == "" {
+		return models.Domains{}, errors.New("domainName is empty")
+	}
+	var domain models.Domains
+	if err := DB.Model(&domain).Where("domain_name = ?", domainName).First(&domain).Error; err != nil {
+		return domain, err
+	}
+	return domain, nil
+}
+
+// GetZoneForDomain 查找域名所属的主域名，存在多个匹配时取最长的一个
+func GetZoneForDomain(domainName string) (models.Domains, error) {
+	domainName = strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(domainName, "*."), "."))
+	if domainName == "" {
+		return models.Domains{}, errors.New("domainName is empty")
+	}
+	// 依次去掉最左侧的标签，得到所有可能的主域名
+	var names []string
+	labels := strings.Split(domainName, ".")
+	for i := 0; i < len(labels)-1; i++ {
+		names = append(names, strings.Join(labels[i:], "."))
+	}
+	var domain models.Domains
+	if err := DB.Model(&domain).Where("domain_name IN ?", names).Order("length(domain_name) desc").First(&domain).Error; err != nil {
+		return domain, err
+	}
+	return domain, nil
+}
+
 // AddDomainInfo 添加域名信息,不存在则创建
 func AddDomainInfo(domainInfo models.DomainInfo) error {
 	domain := DomainInfoToDomain(domainInfo)
@@ -101,17 +137,392 @@ func GetCertificateForId(id int) (models.Certificate, error) {
 // AddCertificateInfo 添加证书信息,不存在则创建
 func AddCertificateInfo(certificateInfo *models.Certificate) error {
 	// 判断是否已经存在,存在则更新,不存在则创建
-	if err := DB.Model(&certificateInfo).Where("id = ?", certificateInfo.Id).First(&certificateInfo).Error; err == nil {
-		if err := DB.Model(&certificateInfo).Updates(&certificateInfo).Error; err != nil {
+	var exist models.Certificate
+	if err := DB.Model(&exist).Where("id = ?", certificateInfo.Id).First(&exist).Error; err == nil {
+		if err := DB.Model(certificateInfo).Updates(certificateInfo).Error; err != nil {
 			return err
 		}
 		return nil
 	}
-	if err := DB.Model(&certificateInfo).Create(&certificateInfo).Error; err != nil {
+	if err := DB.Model(certificateInfo).Create(certificateInfo).Error; err != nil {
+		return err
 	}
 	return nil
 }
 
+// CountCertificatesForSavePath 统计使用同一保存目录的其他证书数量
+func CountCertificatesForSavePath(savePath string, excludeId int) (int64, error) {
+	var count int64
+	err := DB.Model(&models.Certificate{}).Where("save_path = ? AND id <> ?", savePath, excludeId).Count(&count).Error
+	return count, err
+}
+
+// RevokeCertificateInfo 将证书标记为已吊销，并解除域名与证书的关联
+func RevokeCertificateInfo(certId int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Certificate{}).Where("id = ?", certId).Update("state", "revoked").Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Domains{}).Where("certificate_id = ?", certId).Update("certificate_id", 0).Error
+	})
+}
+
+// DeleteCertificateInfo 删除证书及其部署目标、拉取 Token，并解除域名与证书的关联
+func DeleteCertificateInfo(certId int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Domains{}).Where("certificate_id = ?", certId).Update("certificate_id", 0).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("cert_id = ?", certId).Delete(&models.CertificateDeploy{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("cert_id = ?", certId).Delete(&models.CertificatePullToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", certId).Delete(&models.Certificate{}).Error
+	})
+}
+
+// GetCertificatesToRenew 获取到期时间早于指定时间的已签发证书
+func GetCertificatesToRenew(before time.Time) ([]models.Certificate, error) {
+	var certificates []models.Certificate
+	if err := DB.Model(&certificates).Where("state = ? AND not_after < ?", "success", before).Find(&certificates).Error; err != nil {
+		return certificates, err
+	}
+	return certificates, nil
+}
+
+// GetAcmeAccount 根据邮箱与 CA 获取 ACME 账户
+func GetAcmeAccount(email, caDirURL string) (models.AcmeAccount, error) {
+	var account models.AcmeAccount
+	if err := DB.Model(&account).Where("email = ? AND ca_dir_url = ?", email, caDirURL).First(&account).Error; err != nil {
+		return account, err
+	}
+	return account, nil
+}
+
+// SaveAcmeAccount 保存 ACME 账户
+func SaveAcmeAccount(account *models.AcmeAccount) error {
+	account.UpdateTime = time.Now()
+	if account.Id == 0 {
+		account.CreateTime = account.UpdateTime
+		return DB.Model(account).Create(account).Error
+	}
+	return DB.Model(account).Save(account).Error
+}
+
+// GetCertificateDeployList 获取证书的部署目标列表
+func GetCertificateDeployList(certId int) ([]models.CertificateDeploy, error) {
+	var deployList []models.CertificateDeploy
+	if err := DB.Model(&models.CertificateDeploy{}).Where("cert_id = ?", certId).Order("id").Find(&deployList).Error; err != nil {
+		return deployList, err
+	}
+	return deployList, nil
+}
+
+// GetCertificateDeployForId 根据id获取部署目标
+func GetCertificateDeployForId(id int) (models.CertificateDeploy, error) {
+	var deploy models.CertificateDeploy
+	if err := DB.Model(&deploy).Where("id = ?", id).First(&deploy).Error; err != nil {
+		return deploy, err
+	}
+	return deploy, nil
+}
+
+// UpdateCertificateDeployResult 更新部署目标的执行结果
+func UpdateCertificateDeployResult(deploy models.CertificateDeploy) error {
+	return DB.Model(&models.CertificateDeploy{}).Where("id = ?", deploy.Id).Updates(map[string]interface{}{
+		"last_state":  deploy.LastState,
+		"last_result": deploy.LastResult,
+		"last_time":   deploy.LastTime,
+	}).Error
+}
+
+// GetLatestCertificateForDomain 获取覆盖指定域名、仍在有效期内且到期时间最晚的证书，certId 不为 0 时只在该证书中查找
+func GetLatestCertificateForDomain(hostname string, certId int) (models.Certificate, error) {
+	var certificates []models.Certificate
+	query := DB.Model(&models.Certificate{}).Where("state = ? AND not_after > ?", "success", time.Now())
+	if certId != 0 {
+		query = query.Where("id = ?", certId)
+	}
+	if err := query.Order("not_after desc").Find(&certificates).Error; err != nil {
+		return models.Certificate{}, err
+	}
+	for _, certificate := range certificates {
+		if certificate.MatchesDomain(hostname) {
+			return certificate, nil
+		}
+	}
+	return models.Certificate{}, errors.New("no valid certificate for " + hostname)
+}
+
+// GetPullTokenForToken 根据 Token 获取未吊销的拉取 Token 信息
+func GetPullTokenForToken(token string) (models.CertificatePullToken, error) {
+	var pullToken models.CertificatePullToken
+	if token == "" {
+		return pullToken, errors.New("token is empty")
+	}
+	err := DB.Model(&pullToken).Where("token_hash = ? AND revoked = ?", utils.HashToken(token), false).First(&pullToken).Error
+	return pullToken, err
+}
+
+// GetPullTokenList 获取拉取 Token 列表，certId 不为 0 时只返回绑定该证书的 Token
+func GetPullTokenList(certId int) ([]models.CertificatePullToken, error) {
+	var tokenList []models.CertificatePullToken
+	query := DB.Model(&models.CertificatePullToken{})
+	if certId != 0 {
+		query = query.Where("cert_id = ?", certId)
+	}
+	if err := query.Order("id desc").Find(&tokenList).Error; err != nil {
+		return tokenList, err
+	}
+	return tokenList, nil
+}
+
+// GetChallengeClientForUsername 根据用户名获取外部 ACME 客户端
+func GetChallengeClientForUsername(username string) (models.ChallengeClient, error) {
+	var client models.ChallengeClient
+	if username == "" {
+		return client, errors.New("username is empty")
+	}
+	err := DB.Model(&client).Where("username = ?", username).First(&client).Error
+	return client, err
+}
+
+// GetChallengeClientList 获取外部 ACME 客户端列表
+func GetChallengeClientList() ([]models.ChallengeClient, error) {
+	var clientList []models.ChallengeClient
+	if err := DB.Model(&models.ChallengeClient{}).Order("id").Find(&clientList).Error; err != nil {
+		return clientList, err
+	}
+	return clientList, nil
+}
+
+// GetDDNSClientForUsername 根据用户名获取 DynDNS2 凭据
+func GetDDNSClientForUsername(username string) (models.DDNSClient, error) {
+	var client models.DDNSClient
+	if username == "" {
+		return client, errors.New("username is empty")
+	}
+	err := DB.Model(&client).Where("username = ?", username).First(&client).Error
+	return client, err
+}
+
+// GetDDNSClientList 获取 DynDNS2 凭据列表
+func GetDDNSClientList() ([]models.DDNSClient, error) {
+	var clientList []models.DDNSClient
+	if err := DB.Model(&models.DDNSClient{}).Order("id").Find(&clientList).Error; err != nil {
+		return clientList, err
+	}
+	return clientList, nil
+}
+
+// GetFastDataForToken 根据 Token 获取快速解析记录，数据库中只保存 Token 的哈希
+func GetFastDataForToken(token string) (models.FastData, error) {
+	var fastData models.FastData
+	if token == "" {
+		return fastData, errors.New("token is empty")
+	}
+	err := DB.Model(&fastData).Preload("Records").Where("token_hash = ?", utils.HashToken(token)).First(&fastData).Error
+	return fastData, err
+}
+
+// GetFastDataForIp 根据 A / AAAA 记录值获取解析池中的快速解析记录
+func GetFastDataForIp(pool string, ip string) (models.FastData, error) {
+	var fastData models.FastData
+	if ip == "" {
+		return fastData, errors.New("ip is empty")
+	}
+	err := DB.Model(&fastData).Preload("Records").Where("pool = ? AND (ipv4 = ? OR ipv6 = ?)", pool, ip, ip).First(&fastData).Error
+	return fastData, err
+}
+
+// IsFastRecordNameExist 判断主域名下的快速解析主机记录是否已被任意 Token 使用
+func IsFastRecordNameExist(domainName, recordName string) bool {
+	var count, recordCount int64
+	DB.Model(&models.FastData{}).Where("domain_name = ? AND record_name = ?", domainName, recordName).Count(&count)
+	DB.Model(&models.FastRecord{}).Where("domain_name = ? AND record_name = ?", domainName, recordName).Count(&recordCount)
+	return count+recordCount > 0
+}
+
+// ErrFastRecordNameExist 主机记录已被其他快速解析 Token 使用
+var ErrFastRecordNameExist = errors.New("hostname is already in use")
+
+// CreateFastData 在事务中保存新的快速解析记录，主机记录已被其他 Token 的其他主机记录使用时返回 ErrFastRecordNameExist
+// 先写入记录获取数据库写锁再检查，并发写入同名主机记录时只有一个能成功，同表的重复由唯一索引保证
+func CreateFastData(fastData *models.FastData) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(fastData).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.FastRecord{}).Where("domain_name = ? AND record_name = ?", fastData.DomainName, fastData.RecordName).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrFastRecordNameExist
+		}
+		return nil
+	})
+}
+
+// CreateFastRecord 在事务中保存快速解析 Token 的其他主机记录，主机记录已被任意 Token 使用时返回 ErrFastRecordNameExist
+func CreateFastRecord(record *models.FastRecord) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.FastData{}).Where("domain_name = ? AND record_name = ?", record.DomainName, record.RecordName).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrFastRecordNameExist
+		}
+		return nil
+	})
+}
+
+// getFastSequence 获取解析池的快速解析编号，不存在时从解析池的起始编号开始
+func getFastSequence(tx *gorm.DB, pool models.FastConfig) (models.FastSequence, error) {
+	sequence := models.FastSequence{Pool: pool.Name}
+	err := tx.Model(&sequence).Where("pool = ?", pool.Name).First(&sequence).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		sequence.LastId = pool.StartId
+		return sequence, nil
+	}
+	return sequence, err
+}
+
+// NextFastId 在事务中分配解析池的下一个快速解析编号，超过结束编号时返回错误
+func NextFastId(pool models.FastConfig) (int, error) {
+	var id int
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// 先写入编号记录获取数据库写锁，并发分配时不会读取到相同的编号
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.FastSequence{Pool: pool.Name, LastId: pool.StartId}).Error; err != nil {
+			return err
+		}
+		sequence, err := getFastSequence(tx, pool)
+		if err != nil {
+			return err
+		}
+		id = sequence.LastId
+		if pool.EndId > 0 && id > pool.EndId {
+			return errors.New("fast pool is exhausted")
+		}
+		sequence.LastId++
+		return tx.Save(&sequence).Error
+	})
+	return id, err
+}
+
+// GetFastDataForId 根据Id获取快速解析记录
+func GetFastDataForId(id int) (models.FastData, error) {
+	var fastData models.FastData
+	err := DB.Model(&fastData).Preload("Records").Where("id = ?", id).First(&fastData).Error
+	return fastData, err
+}
+
+// GetFastRecordForId 根据Id获取快速解析 Token 的其他主机记录
+func GetFastRecordForId(id int) (models.FastRecord, error) {
+	var record models.FastRecord
+	err := DB.Model(&record).Where("id = ?", id).First(&record).Error
+	return record, err
+}
+
+// DeleteFastRecord 删除快速解析 Token 的其他主机记录
+func DeleteFastRecord(id int) error {
+	return DB.Delete(&models.FastRecord{}, id).Error
+}
+
+// GetFastDataList 获取快速解析记录列表，keyWord 匹配主机记录、备注名称与 IP
+func GetFastDataList(keyWord string, page, pageSize int) ([]models.FastData, int64, error) {
+	var fastDataList []models.FastData
+	var total int64
+	query := DB.Model(&models.FastData{})
+	if keyWord != "" {
+		like := "%" + keyWord + "%"
+		query = query.Where("record_name LIKE ? OR name LIKE ? OR ipv4 LIKE ? OR ipv6 LIKE ?", like, like, like, like)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return fastDataList, total, err
+	}
+	err := query.Preload("Records").Order("id").Limit(pageSize).Offset((page - 1) * pageSize).Find(&fastDataList).Error
+	return fastDataList, total, err
+}
+
+// GetFastDataWithLifecycle 获取设置了过期时间或失效天数的快速解析记录
+func GetFastDataWithLifecycle() ([]models.FastData, error) {
+	var fastDataList []models.FastData
+	err := DB.Model(&models.FastData{}).Preload("Records").Where("inactive_days > 0 OR expire_time > ?", time.Time{}).Find(&fastDataList).Error
+	return fastDataList, err
+}
+
+// GetFastDataInactiveSince 获取 before 之后客户端没有再上报过的快速解析记录，用于检查长时间未上报的主机
+// 与 FastData.LastActiveTime 一致，从未上报时使用创建时间，管理员修改记录不影响判断
+func GetFastDataInactiveSince(before time.Time) ([]models.FastData, error) {
+	var fastDataList []models.FastData
+	err := DB.Model(&models.FastData{}).
+		Where("(last_seen_time > ? AND last_seen_time < ?) OR ((last_seen_time IS NULL OR last_seen_time <= ?) AND create_time < ?)", time.Time{}, before, time.Time{}, before).
+		Find(&fastDataList).Error
+	return fastDataList, err
+}
+
+// SetFastDataNotified 记录发送未上报通知的时间，不修改更新时间
+func SetFastDataNotified(id int, notifiedTime time.Time) error {
+	return DB.Model(&models.FastData{}).Where("id = ?", id).UpdateColumn("notified_time", notifiedTime).Error
+}
+
+// CreateFastHistory 保存快速解析变更历史
+func CreateFastHistory(history *models.FastHistory) error {
+	return DB.Create(history).Error
+}
+
+// GetFastHistoryList 获取快速解析变更历史，按时间倒序，fastId 为 0 时不限制，keyWord 匹配域名与 IP
+func GetFastHistoryList(fastId int, keyWord string, page, pageSize int) ([]models.FastHistory, int64, error) {
+	var historyList []models.FastHistory
+	var total int64
+	query := DB.Model(&models.FastHistory{})
+	if fastId > 0 {
+		query = query.Where("fast_id = ?", fastId)
+	}
+	if keyWord != "" {
+		like := "%" + keyWord + "%"
+		query = query.Where("fqdn LIKE ? OR old_ip LIKE ? OR new_ip LIKE ? OR source_ip LIKE ?", like, like, like, like)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return historyList, total, err
+	}
+	err := query.Order("id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&historyList).Error
+	return historyList, total, err
+}
+
+// DeleteFastData 删除快速解析记录及其他主机记录
+func DeleteFastData(id int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("fast_id = ?", id).Delete(&models.FastRecord{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.FastData{}, id).Error
+	})
+}
+
+// SaveFastData 在事务中保存快速解析记录及其他主机记录
+func SaveFastData(fastData *models.FastData) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(fastData).Error; err != nil {
+			return err
+		}
+		for i := range fastData.Records {
+			fastData.Records[i].FastId = fastData.Id
+			if err := tx.Save(&fastData.Records[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // GetTaskInfoList 获取任务日志列表
 func GetTaskInfoList(taskId string) ([]models.CertificateTask, error) {
 	if taskId == "" {
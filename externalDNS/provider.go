@@ -0,0 +1,476 @@
+package externalDNS
+
+import (
+	"DDNSServer/DDNS"
+	"DDNSServer/models"
+	"DDNSServer/models/requestModel"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	zoneCacheTime  = 5 * time.Minute
+	recordPageSize = 500
+	domainPageSize = 100
+)
+
+// supportedRecordTypes ExternalDNS 管理的记录类型
+var supportedRecordTypes = map[string]bool{"A": true, "AAAA": true, "CNAME": true, "TXT": true}
+
+// zone 可管理的主域名及其账户
+type zone struct {
+	models.DomainInfo
+	provider models.RecordProvider
+}
+
+// domainFilter 域名过滤，规则与 ExternalDNS 的 DomainFilter 一致：域名本身或其子域名
+type domainFilter struct {
+	include []string
+	exclude []string
+}
+
+func newDomainFilter(include, exclude []string) domainFilter {
+	normalize := func(list []string) []string {
+		var result []string
+		for _, name := range list {
+			if name = models.CanonicalDNSName(strings.TrimSpace(name)); name != "" {
+				result = append(result, name)
+			}
+		}
+		return result
+	}
+	return domainFilter{include: normalize(include), exclude: normalize(exclude)}
+}
+
+func matchDomain(name, domain string) bool {
+	return name == domain || strings.HasSuffix(name, "."+domain)
+}
+
+// Match 判断域名是否在管理范围内
+func (f domainFilter) Match(name string) bool {
+	name = models.CanonicalDNSName(name)
+	for _, domain := range f.exclude {
+		if matchDomain(name, domain) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, domain := range f.include {
+		if matchDomain(name, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchZone 判断主域名是否可能包含管理范围内的记录
+func (f domainFilter) MatchZone(zoneName string) bool {
+	if f.Match(zoneName) {
+		return true
+	}
+	zoneName = models.CanonicalDNSName(zoneName)
+	for _, domain := range f.include {
+		if matchDomain(domain, zoneName) {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookProvider 基于 RecordProvider 实现 ExternalDNS webhook provider
+type webhookProvider struct {
+	config    models.ExternalDNSConfig
+	filter    domainFilter
+	mu        sync.Mutex
+	zones     []zone
+	zonesTime time.Time
+}
+
+func newWebhookProvider(config models.ExternalDNSConfig) *webhookProvider {
+	return &webhookProvider{
+		config: config,
+		filter: newDomainFilter(config.DomainFilter, config.ExcludeDomains),
+	}
+}
+
+// getZones 获取可管理的主域名列表，结果缓存一段时间，避免频繁调用云服务商接口
+func (p *webhookProvider) getZones() ([]zone, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.zones != nil && time.Since(p.zonesTime) < zoneCacheTime {
+		return p.zones, nil
+	}
+	accountNames := p.config.Accounts
+	if len(accountNames) == 0 {
+		for _, account := range models.AccountConfig.Accounts {
+			accountNames = append(accountNames, account.Name)
+		}
+	}
+	var zones []zone
+	for _, accountName := range accountNames {
+		account, err := DDNS.GetAccount(accountName)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", accountName, err)
+		}
+		provider, err := DDNS.NewBaseProvider(account)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", accountName, err)
+		}
+		domains, err := listDomains(provider)
+		if err != nil {
+			return nil, fmt.Errorf("%s: 获取域名列表失败: %v", accountName, err)
+		}
+		for _, domain := range domains {
+			domain.DomainName = models.CanonicalDNSName(domain.DomainName)
+			if p.filter.MatchZone(domain.DomainName) {
+				zones = append(zones, zone{DomainInfo: domain, provider: provider})
+			}
+		}
+	}
+	p.zones, p.zonesTime = zones, time.Now()
+	return zones, nil
+}
+
+// listDomains 分页获取账户下的全部域名，部分云服务商不支持分页，出现重复域名时结束
+func listDomains(provider models.RecordProvider) ([]models.DomainInfo, error) {
+	var domains []models.DomainInfo
+	exist := map[string]bool{}
+	for page := int64(1); ; page++ {
+		result, err := provider.GetDomainList(models.DomainsSearch{PageNumber: page, PageSize: domainPageSize})
+		if err != nil {
+			return nil, err
+		}
+		added := 0
+		for _, domain := range result.Domains {
+			if !exist[domain.DomainName] {
+				exist[domain.DomainName] = true
+				domains = append(domains, domain)
+				added++
+			}
+		}
+		if added == 0 || len(result.Domains) < domainPageSize {
+			return domains, nil
+		}
+	}
+}
+
+// listRecords 分页获取主域名下的记录
+func listRecords(z zone, search models.DNSSearch) ([]models.RecordInfo, error) {
+	search.DomainId, search.DomainName = z.Id, z.DomainName
+	search.PageSize = recordPageSize
+	var records []models.RecordInfo
+	for page := int64(1); ; page++ {
+		search.PageNumber = page
+		result, err := z.provider.GetRecordList(search)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, result.Records...)
+		if len(result.Records) == 0 || int64(len(records)) >= result.TotalCount {
+			return records, nil
+		}
+	}
+}
+
+// findZone 找到域名所属的主域名（最长匹配）
+func findZone(zones []zone, name string) (zone, bool) {
+	var result zone
+	found := false
+	for _, z := range zones {
+		if matchDomain(name, z.DomainName) && len(z.DomainName) > len(result.DomainName) {
+			result, found = z, true
+		}
+	}
+	return result, found
+}
+
+// recordFQDN 获取记录的完整域名，Cloudflare 返回完整域名，其余云服务商返回主机记录
+func recordFQDN(recordName, zoneName string) string {
+	recordName = models.CanonicalDNSName(recordName)
+	switch {
+	case recordName == "" || recordName == "@":
+		return zoneName
+	case matchDomain(recordName, zoneName):
+		return recordName
+	default:
+		return recordName + "." + zoneName
+	}
+}
+
+// recordName 获取完整域名在主域名下的主机记录
+func recordName(fqdn, zoneName string) string {
+	if fqdn == zoneName {
+		return "@"
+	}
+	return strings.TrimSuffix(fqdn, "."+zoneName)
+}
+
+// recordTTL 获取记录 TTL，腾讯云的 TTL 保存在 TtlTC 中
+func recordTTL(record models.RecordInfo) int64 {
+	if record.Ttl == 0 {
+		return int64(record.TtlTC)
+	}
+	return record.Ttl
+}
+
+// toProviderTarget 写入云服务商前去掉 TXT 记录的引号，部分云服务商会拒绝或重复转义引号
+func toProviderTarget(recordType, target string) string {
+	if recordType == "TXT" {
+		return strings.Trim(target, `"`)
+	}
+	if recordType == "CNAME" {
+		return strings.TrimSuffix(target, ".")
+	}
+	return target
+}
+
+// fromProviderTarget 读取时为 ExternalDNS 的所有权 TXT 记录补回引号，与 ExternalDNS 期望的值保持一致，避免每次同步都产生更新
+func fromProviderTarget(recordType, target string) string {
+	if recordType == "TXT" && strings.HasPrefix(target, "heritage=") {
+		return `"` + target + `"`
+	}
+	if recordType == "CNAME" {
+		return strings.TrimSuffix(target, ".")
+	}
+	return target
+}
+
+// Records 获取管理范围内的全部记录，同名同类型的记录合并为一个 Endpoint
+func (p *webhookProvider) Records() ([]*requestModel.ExternalDNSEndpoint, error) {
+	zones, err := p.getZones()
+	if err != nil {
+		return nil, err
+	}
+	endpoints := map[string]*requestModel.ExternalDNSEndpoint{}
+	for _, z := range zones {
+		records, err := listRecords(z, models.DNSSearch{})
+		if err != nil {
+			return nil, fmt.Errorf("%s: 获取记录列表失败: %v", z.DomainName, err)
+		}
+		for _, record := range records {
+			recordType := strings.ToUpper(record.RecordType)
+			if !supportedRecordTypes[recordType] || strings.EqualFold(record.Status, "Disable") {
+				continue
+			}
+			name := recordFQDN(record.RecordName, z.DomainName)
+			// 子域名单独托管时，记录归属最长匹配的主域名
+			if owner, _ := findZone(zones, name); owner.DomainName != z.DomainName || !p.filter.Match(name) {
+				continue
+			}
+			key := name + "|" + recordType
+			endpoint, ok := endpoints[key]
+			if !ok {
+				endpoint = &requestModel.ExternalDNSEndpoint{DNSName: name, RecordType: recordType, RecordTTL: recordTTL(record)}
+				endpoints[key] = endpoint
+			}
+			endpoint.Targets = append(endpoint.Targets, fromProviderTarget(recordType, record.RecordContent))
+		}
+	}
+	result := make([]*requestModel.ExternalDNSEndpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		sort.Strings(endpoint.Targets)
+		result = append(result, endpoint)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].DNSName == result[j].DNSName {
+			return result[i].RecordType < result[j].RecordType
+		}
+		return result[i].DNSName < result[j].DNSName
+	})
+	return result, nil
+}
+
+// AdjustEndpoints 规范化 ExternalDNS 提交的 Endpoint，去掉不在管理范围内与不支持的记录
+func (p *webhookProvider) AdjustEndpoints(endpoints []*requestModel.ExternalDNSEndpoint) []*requestModel.ExternalDNSEndpoint {
+	result := make([]*requestModel.ExternalDNSEndpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		endpoint.DNSName = models.CanonicalDNSName(endpoint.DNSName)
+		endpoint.RecordType = strings.ToUpper(endpoint.RecordType)
+		if !supportedRecordTypes[endpoint.RecordType] || !p.filter.Match(endpoint.DNSName) {
+			continue
+		}
+		if endpoint.RecordTTL == 0 {
+			endpoint.RecordTTL = p.config.DefaultTTL
+		}
+		for i, target := range endpoint.Targets {
+			if endpoint.RecordType == "CNAME" {
+				endpoint.Targets[i] = strings.TrimSuffix(target, ".")
+			}
+		}
+		// 云服务商不支持 ExternalDNS 的 provider 专属属性
+		endpoint.ProviderSpecific = nil
+		result = append(result, endpoint)
+	}
+	return result
+}
+
+// endpointZone 获取 Endpoint 所属的主域名
+func (p *webhookProvider) endpointZone(zones []zone, endpoint *requestModel.ExternalDNSEndpoint) (zone, string, error) {
+	name := models.CanonicalDNSName(endpoint.DNSName)
+	if !p.filter.Match(name) {
+		return zone{}, "", fmt.Errorf("%s 不在管理范围内", name)
+	}
+	z, ok := findZone(zones, name)
+	if !ok {
+		return zone{}, "", fmt.Errorf("%s 没有可管理的主域名", name)
+	}
+	return z, name, nil
+}
+
+// existingRecords 获取 Endpoint 对应的现有记录
+func existingRecords(z zone, name, recordType string) ([]models.RecordInfo, error) {
+	records, err := listRecords(z, models.DNSSearch{RRKeyWord: recordName(name, z.DomainName), TypeKeyWord: recordType})
+	if err != nil {
+		return nil, err
+	}
+	// 关键字搜索可能是模糊匹配，需要再次过滤
+	var result []models.RecordInfo
+	for _, record := range records {
+		if strings.EqualFold(record.RecordType, recordType) && recordFQDN(record.RecordName, z.DomainName) == name {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
+
+// createEndpoint 添加 Endpoint 的全部记录
+func (p *webhookProvider) createEndpoint(zones []zone, endpoint *requestModel.ExternalDNSEndpoint) error {
+	z, name, err := p.endpointZone(zones, endpoint)
+	if err != nil {
+		return err
+	}
+	ttl := endpoint.RecordTTL
+	if ttl == 0 {
+		ttl = p.config.DefaultTTL
+	}
+	for _, target := range endpoint.Targets {
+		_, err = z.provider.AddRecord(models.RecordInfo{
+			DomainId:      z.Id,
+			DomainName:    z.DomainName,
+			RecordName:    recordName(name, z.DomainName),
+			RecordType:    endpoint.RecordType,
+			RecordContent: toProviderTarget(endpoint.RecordType, target),
+			Ttl:           ttl,
+			TtlTC:         uint64(ttl),
+		})
+		if err != nil {
+			return fmt.Errorf("添加记录 %s %s 失败: %v", name, endpoint.RecordType, err)
+		}
+	}
+	return nil
+}
+
+// deleteEndpoint 删除 Endpoint 中指定值的记录，targets 为空时删除该名称与类型的全部记录
+func (p *webhookProvider) deleteEndpoint(zones []zone, endpoint *requestModel.ExternalDNSEndpoint, targets []string) error {
+	z, name, err := p.endpointZone(zones, endpoint)
+	if err != nil {
+		return err
+	}
+	records, err := existingRecords(z, name, endpoint.RecordType)
+	if err != nil {
+		return err
+	}
+	remove := map[string]bool{}
+	for _, target := range targets {
+		remove[toProviderTarget(endpoint.RecordType, target)] = true
+	}
+	for _, record := range records {
+		if len(targets) > 0 && !remove[toProviderTarget(endpoint.RecordType, record.RecordContent)] {
+			continue
+		}
+		if _, err = z.provider.DeleteRecord(z.DomainName, record.Id); err != nil {
+			return fmt.Errorf("删除记录 %s %s 失败: %v", name, endpoint.RecordType, err)
+		}
+	}
+	return nil
+}
+
+// updateEndpoint 按差异更新 Endpoint：删除不再需要的值，添加新值，TTL 变化时更新保留的记录
+func (p *webhookProvider) updateEndpoint(zones []zone, oldEndpoint, newEndpoint *requestModel.ExternalDNSEndpoint) error {
+	z, name, err := p.endpointZone(zones, newEndpoint)
+	if err != nil {
+		return err
+	}
+	records, err := existingRecords(z, name, newEndpoint.RecordType)
+	if err != nil {
+		return err
+	}
+	newTargets := map[string]bool{}
+	for _, target := range newEndpoint.Targets {
+		newTargets[toProviderTarget(newEndpoint.RecordType, target)] = true
+	}
+	ttl := newEndpoint.RecordTTL
+	if ttl == 0 {
+		ttl = p.config.DefaultTTL
+	}
+	var deleteTargets []string
+	for _, record := range records {
+		content := toProviderTarget(newEndpoint.RecordType, record.RecordContent)
+		if !newTargets[content] {
+			deleteTargets = append(deleteTargets, content)
+			continue
+		}
+		delete(newTargets, content)
+		if ttl != 0 && recordTTL(record) != ttl {
+			record.DomainId, record.DomainName = z.Id, z.DomainName
+			record.RecordName = recordName(name, z.DomainName)
+			record.Ttl, record.TtlTC = ttl, uint64(ttl)
+			if _, err = z.provider.UpdateRecord(record); err != nil {
+				return fmt.Errorf("更新记录 %s %s 失败: %v", name, newEndpoint.RecordType, err)
+			}
+		}
+	}
+	if len(deleteTargets) > 0 {
+		if err = p.deleteEndpoint(zones, oldEndpoint, deleteTargets); err != nil {
+			return err
+		}
+	}
+	var addTargets []string
+	for _, target := range newEndpoint.Targets {
+		if newTargets[toProviderTarget(newEndpoint.RecordType, target)] {
+			addTargets = append(addTargets, target)
+		}
+	}
+	if len(addTargets) == 0 {
+		return nil
+	}
+	return p.createEndpoint(zones, &requestModel.ExternalDNSEndpoint{
+		DNSName:    newEndpoint.DNSName,
+		RecordType: newEndpoint.RecordType,
+		RecordTTL:  ttl,
+		Targets:    addTargets,
+	})
+}
+
+// ApplyChanges 应用 ExternalDNS 计算出的变更，先删除再更新最后添加，单条失败不影响其余变更
+func (p *webhookProvider) ApplyChanges(changes requestModel.ExternalDNSChanges) error {
+	zones, err := p.getZones()
+	if err != nil {
+		return err
+	}
+	if len(changes.UpdateOld) != len(changes.UpdateNew) {
+		return errors.New("UpdateOld 与 UpdateNew 数量不一致")
+	}
+	var errs []error
+	for _, endpoint := range changes.Delete {
+		errs = append(errs, p.deleteEndpoint(zones, endpoint, endpoint.Targets))
+	}
+	for i, endpoint := range changes.UpdateNew {
+		errs = append(errs, p.updateEndpoint(zones, changes.UpdateOld[i], endpoint))
+	}
+	for _, endpoint := range changes.Create {
+		errs = append(errs, p.createEndpoint(zones, endpoint))
+	}
+	err = errors.Join(errs...)
+	if err != nil {
+		slog.Error("ExternalDNS 变更应用失败", "err", err)
+	}
+	return err
+}
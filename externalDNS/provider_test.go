@@ -0,0 +1,91 @@
+package externalDNS
+
+import (
+	"DDNSServer/models"
+	"DDNSServer/models/requestModel"
+	"DDNSServer/testutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestProvider(fake *testutil.FakeProvider, filter []string) *webhookProvider {
+	p := newWebhookProvider(models.ExternalDNSConfig{DomainFilter: filter, DefaultTTL: 600})
+	p.zones = []zone{{DomainInfo: models.DomainInfo{Domains: models.Domains{Id: "1", DomainName: "a.com"}}, provider: fake}}
+	p.zonesTime = time.Now()
+	return p
+}
+
+func TestDomainFilter(t *testing.T) {
+	filter := newDomainFilter([]string{"k8s.a.com"}, []string{"internal.k8s.a.com"})
+	for name, want := range map[string]bool{
+		"k8s.a.com":            true,
+		"www.k8s.a.com":        true,
+		"x.internal.k8s.a.com": false,
+		"a.com":                false,
+		"xk8s.a.com":           false,
+	} {
+		if filter.Match(name) != want {
+			t.Errorf("Match(%q) = %v, want %v", name, !want, want)
+		}
+	}
+	if !filter.MatchZone("a.com") || filter.MatchZone("b.com") {
+		t.Error("MatchZone 应包含过滤域名的上级主域名")
+	}
+}
+
+func TestRecords(t *testing.T) {
+	fake := &testutil.FakeProvider{Records: []models.RecordInfo{
+		{Id: "1", DomainName: "a.com", RecordName: "www", RecordType: "A", RecordContent: "192.0.2.2", Ttl: 600},
+		{Id: "2", DomainName: "a.com", RecordName: "www", RecordType: "A", RecordContent: "192.0.2.1", Ttl: 600},
+		{Id: "3", DomainName: "a.com", RecordName: "www", RecordType: "TXT", RecordContent: "heritage=external-dns,external-dns/owner=default"},
+		{Id: "4", DomainName: "a.com", RecordName: "@", RecordType: "MX", RecordContent: "mx.a.com"},
+	}}
+	records, err := newTestProvider(fake, nil).Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*requestModel.ExternalDNSEndpoint{
+		{DNSName: "www.a.com", RecordType: "A", RecordTTL: 600, Targets: []string{"192.0.2.1", "192.0.2.2"}},
+		{DNSName: "www.a.com", RecordType: "TXT", Targets: []string{`"heritage=external-dns,external-dns/owner=default"`}},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("Records = %+v, want %+v", records, want)
+	}
+}
+
+func TestApplyChanges(t *testing.T) {
+	fake := &testutil.FakeProvider{Records: []models.RecordInfo{
+		{Id: "a", DomainName: "a.com", RecordName: "old", RecordType: "A", RecordContent: "192.0.2.1"},
+		{Id: "b", DomainName: "a.com", RecordName: "web", RecordType: "A", RecordContent: "192.0.2.1", Ttl: 600},
+	}}
+	p := newTestProvider(fake, nil)
+	err := p.ApplyChanges(requestModel.ExternalDNSChanges{
+		Create: []*requestModel.ExternalDNSEndpoint{
+			{DNSName: "new.a.com", RecordType: "TXT", Targets: []string{`"heritage=external-dns"`}},
+		},
+		UpdateOld: []*requestModel.ExternalDNSEndpoint{{DNSName: "web.a.com", RecordType: "A", Targets: []string{"192.0.2.1"}}},
+		UpdateNew: []*requestModel.ExternalDNSEndpoint{{DNSName: "web.a.com", RecordType: "A", Targets: []string{"192.0.2.2"}}},
+		Delete:    []*requestModel.ExternalDNSEndpoint{{DNSName: "old.a.com", RecordType: "A", Targets: []string{"192.0.2.1"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, record := range fake.Records {
+		got[record.RecordName+"|"+record.RecordType] = record.RecordContent
+	}
+	want := map[string]string{"new|TXT": "heritage=external-dns", "web|A": "192.0.2.2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+
+	// 不在管理范围内的变更返回错误
+	p = newTestProvider(fake, []string{"k8s.a.com"})
+	err = p.ApplyChanges(requestModel.ExternalDNSChanges{
+		Create: []*requestModel.ExternalDNSEndpoint{{DNSName: "www.a.com", RecordType: "A", Targets: []string{"192.0.2.3"}}},
+	})
+	if err == nil {
+		t.Error("不在管理范围内的变更应返回错误")
+	}
+}
@@ -0,0 +1,87 @@
+package externalDNS
+
+import (
+	"DDNSServer/models"
+	"DDNSServer/models/requestModel"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+)
+
+// mediaType ExternalDNS webhook 协议的内容类型
+const mediaType = "application/external.dns.webhook+json;version=1"
+
+// writeJSON 按 webhook 协议的内容类型返回 JSON
+func writeJSON(c *gin.Context, code int, obj any) {
+	c.Header("Content-Type", mediaType)
+	c.JSON(code, obj)
+}
+
+// newRouter 注册 webhook 协议的路由
+func newRouter(provider *webhookProvider) *gin.Engine {
+	r := gin.New()
+	r.Use(gin.Recovery())
+	// 协商，返回管理的域名范围
+	r.GET("/", func(c *gin.Context) {
+		writeJSON(c, http.StatusOK, requestModel.ExternalDNSDomainFilter{
+			Include: provider.filter.include,
+			Exclude: provider.filter.exclude,
+		})
+	})
+	// 获取记录
+	r.GET("/records", func(c *gin.Context) {
+		records, err := provider.Records()
+		if err != nil {
+			slog.Error("ExternalDNS 获取记录失败", "err", err)
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(c, http.StatusOK, records)
+	})
+	// 应用变更
+	r.POST("/records", func(c *gin.Context) {
+		var changes requestModel.ExternalDNSChanges
+		if err := c.ShouldBindJSON(&changes); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := provider.ApplyChanges(changes); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	// 规范化 Endpoint
+	r.POST("/adjustendpoints", func(c *gin.Context) {
+		var endpoints []*requestModel.ExternalDNSEndpoint
+		if err := c.ShouldBindJSON(&endpoints); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(c, http.StatusOK, provider.AdjustEndpoints(endpoints))
+	})
+	// 健康检查
+	r.GET("/healthz", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return r
+}
+
+// Start 启动 ExternalDNS webhook 服务，默认只监听本地地址，与 ExternalDNS 作为 sidecar 部署
+func Start() {
+	config := models.AccountConfig.ExternalDNS
+	if !config.Enable {
+		return
+	}
+	listen := config.Listen
+	if listen == "" {
+		listen = "127.0.0.1:8888"
+	}
+	r := newRouter(newWebhookProvider(config))
+	go func() {
+		slog.Info("ExternalDNS webhook 服务已启动", "listen", listen)
+		if err := r.Run(listen); err != nil {
+			slog.Error("ExternalDNS webhook 服务启动失败", "err", err)
+		}
+	}()
+}
@@ -2,33 +2,60 @@ package main
 
 import (
 	"DDNSServer/certificate"
+	"DDNSServer/challengeDNS"
+	"DDNSServer/client"
 	"DDNSServer/db"
+	"DDNSServer/externalDNS"
 	"DDNSServer/models"
 	"DDNSServer/utils"
+	"DDNSServer/views"
 	_ "embed"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"log"
+	"os"
 	"time"
 )
 
 //go:embed config.toml.example
 var config string
 
+//go:embed client.toml.example
+var clientConfig string
+
 func main() {
+	// 客户端模式
+	if len(os.Args) > 1 && os.Args[1] == "client" {
+		if err := client.Run(os.Args[2:], clientConfig); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	gin.Logger()
 	if utils.InitConfig(config) {
 		return
 	}
-	// 启用证书任务
-	go certificate.StartTaskProcessor()
 	// 初始化数据库
 	err := db.InitDB()
 	if err != nil {
 		log.Fatal(err)
 	}
+	// 启用证书任务
+	certificate.InitTaskClient()
+	go certificate.StartTaskProcessor()
+	go certificate.StartRenewScheduler()
+	// 定期清理失效的快速解析 Token
+	go views.StartFastReaper()
+	// 启用内置 DNS 服务
+	challengeDNS.Start()
+	// 启用 ExternalDNS webhook 服务
+	externalDNS.Start()
 
 	r := gin.Default()
+	// 只信任配置的反向代理，避免伪造的 X-Forwarded-For 影响日志中的客户端 IP
+	if err = r.SetTrustedProxies(models.AccountConfig.BaseConfig.TrustedProxies); err != nil {
+		log.Fatal(err)
+	}
 
 	// 定义CORS配置
 	CORSConfig := cors.Config{
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"net"
 	"strings"
 	"time"
 )
@@ -21,7 +22,7 @@ type Domains struct {
 
 type Certificate struct {
 	Id         int       `gorm:"primaryKey" json:"id"`
-	State      string    `gorm:"null,default:'wait'" json:"state"` // 证书状态 wait 等待中 | apply 申请中 | success 申请成功 | fail 申请失败
+	State      string    `gorm:"null,default:'wait'" json:"state"` // 证书状态 wait 等待中 | apply 申请中 | success 申请成功 | fail 申请失败 | renew 续期中 | revoked 已吊销
 	TaskId     string    `gorm:"null" json:"taskId"`               // 任务ID
 	SavePath   string    `gorm:"null" json:"savePath"`
 	Issuer     string    `gorm:"null" json:"issuer"`     // 颁发者
@@ -31,6 +32,13 @@ type Certificate struct {
 	DNSNames   string    `gorm:"null" json:"DNSNames"`   // SAN中的DNS名称
 	CommonName string    `gorm:"null" json:"commonName"` // 主题中的Common Name
 	DomainList string    `gorm:"null" json:"domainList"` // 允许的域名列表
+	// 申请信息，用于自动续期
+	AccountName  string `gorm:"null" json:"accountName"`  // 申请所使用的账户名称
+	ApplyDomains string `gorm:"null" json:"applyDomains"` // 申请时提交的域名列表
+	SanList      string `gorm:"null" json:"sanList"`      // 申请时提交的 SAN 列表
+	CA           string `gorm:"null" json:"ca"`           // 签发证书的 CA 目录地址
+	KeyType      string `gorm:"null" json:"keyType"`      // 证书密钥类型
+	AcmeEmail    string `gorm:"null" json:"acmeEmail"`    // 签发证书的 ACME 账户邮箱，续期时使用同一账户
 }
 
 type CertificateTask struct {
@@ -43,6 +51,117 @@ type CertificateTask struct {
 	CreateTime time.Time `gorm:"null" json:"createTime"`
 }
 
+// CertificateDeploy 证书部署目标，证书签发或续期成功后执行
+type CertificateDeploy struct {
+	Id         int       `gorm:"primaryKey" json:"id"`
+	CertId     int       `gorm:"not null;index" json:"certId"`
+	Type       string    `gorm:"not null" json:"type"`             // 部署类型 copy 复制文件 | command 执行命令 | webhook 推送
+	CertPath   string    `gorm:"null" json:"certPath"`             // 证书（fullchain）复制路径
+	KeyPath    string    `gorm:"null" json:"keyPath"`              // 私钥复制路径
+	Owner      string    `gorm:"null" json:"owner"`                // 文件所有者 user:group
+	FileMode   string    `gorm:"null" json:"fileMode"`             // 文件权限，例如 0600
+	Command    string    `gorm:"null" json:"command"`              // 部署命令，例如 nginx -s reload
+	WebhookUrl string    `gorm:"null" json:"webhookUrl"`           // webhook 地址
+	WithKey    bool      `gorm:"null" json:"withKey"`              // webhook 是否附带私钥
+	Enabled    bool      `gorm:"null;default:true" json:"enabled"` // 是否启用
+	LastState  string    `gorm:"null" json:"lastState"`            // 最近一次部署状态 success | fail
+	LastResult string    `gorm:"null" json:"lastResult"`           // 最近一次部署结果
+	LastTime   time.Time `gorm:"null" json:"lastTime"`             // 最近一次部署时间
+	CreateTime time.Time `gorm:"null" json:"createTime"`
+}
+
+// CertificatePullToken 证书拉取 Token，只允许读取绑定证书或指定域名下的证书
+type CertificatePullToken struct {
+	Id           int       `gorm:"primaryKey" json:"id"`
+	Name         string    `gorm:"null" json:"name"`                  // 备注名称
+	TokenHash    string    `gorm:"not null;uniqueIndex" json:"-"`     // Token 哈希
+	TokenPrefix  string    `gorm:"null" json:"tokenPrefix"`           // Token 前缀，便于识别
+	CertId       int       `gorm:"null;index" json:"certId"`          // 绑定的证书，为 0 时按域名限制
+	Domain       string    `gorm:"null" json:"domain"`                // 允许拉取的域名（包含子域名）
+	Revoked      bool      `gorm:"null;default:false" json:"revoked"` // 是否已吊销
+	CreateTime   time.Time `gorm:"null" json:"createTime"`
+	LastUsedTime time.Time `gorm:"null" json:"lastUsedTime"`
+}
+
+// AllowHostname 判断 Token 是否允许拉取指定域名的证书
+func (t *CertificatePullToken) AllowHostname(hostname string) bool {
+	if t.Domain == "" {
+		return t.CertId != 0
+	}
+	domain := strings.ToLower(t.Domain)
+	return hostname == domain || strings.HasSuffix(hostname, "."+domain)
+}
+
+// ChallengeClient 外部 ACME 客户端凭据（acme-dns、httpreq），只允许为指定域名写入验证记录
+type ChallengeClient struct {
+	Id           int       `gorm:"primaryKey" json:"id"`
+	Name         string    `gorm:"null" json:"name"`                      // 备注名称
+	Username     string    `gorm:"not null;uniqueIndex" json:"username"`  // 用户名
+	KeyHash      string    `gorm:"not null" json:"-"`                     // 密钥哈希
+	Subdomain    string    `gorm:"not null;uniqueIndex" json:"subdomain"` // acme-dns 子域名
+	Domains      string    `gorm:"not null" json:"domains"`               // 允许的域名列表（包含子域名），逗号分隔
+	AllowFrom    string    `gorm:"null" json:"allowFrom"`                 // 允许的来源 IP 段，逗号分隔，为空时不限制
+	TxtValues    string    `gorm:"null" json:"-"`                         // acme-dns 当前的 TXT 记录值，最多保留两个
+	CreateTime   time.Time `gorm:"null" json:"createTime"`
+	LastUsedTime time.Time `gorm:"null" json:"lastUsedTime"`
+}
+
+// AllowDomain 判断客户端是否允许为指定域名写入验证记录
+func (c *ChallengeClient) AllowDomain(domain string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(domain, "*."), "."))
+	for _, allowed := range strings.Split(c.Domains, ",") {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed != "" && (domain == allowed || strings.HasSuffix(domain, "."+allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowIP 判断来源 IP 是否在允许的 IP 段内
+func (c *ChallengeClient) AllowIP(ip string) bool {
+	if strings.TrimSpace(c.AllowFrom) == "" {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	return addr != nil && ipInList(addr, strings.Split(c.AllowFrom, ","))
+}
+
+// DDNSClient DynDNS2 协议（/nic/update）的专用凭据，只允许更新指定的完整域名
+type DDNSClient struct {
+	Id           int       `gorm:"primaryKey" json:"id"`
+	Name         string    `gorm:"null" json:"name"`                     // 备注名称
+	Username     string    `gorm:"not null;uniqueIndex" json:"username"` // 用户名
+	KeyHash      string    `gorm:"not null" json:"-"`                    // 密码哈希
+	Hostnames    string    `gorm:"not null" json:"hostnames"`            // 允许更新的完整域名，逗号分隔
+	CreateTime   time.Time `gorm:"null" json:"createTime"`
+	LastUsedTime time.Time `gorm:"null" json:"lastUsedTime"`
+}
+
+// AllowHostname 判断凭据是否允许更新指定域名
+func (c *DDNSClient) AllowHostname(hostname string) bool {
+	for _, allowed := range strings.Split(c.Hostnames, ",") {
+		if strings.ToLower(strings.TrimSpace(allowed)) == hostname {
+			return true
+		}
+	}
+	return false
+}
+
+// AcmeAccount ACME 账户信息，按邮箱与 CA 保存，避免每次申请重复注册
+type AcmeAccount struct {
+	Id           int       `gorm:"primaryKey" json:"id"`
+	Email        string    `gorm:"not null;uniqueIndex:idx_acme_account" json:"email"`
+	CADirURL     string    `gorm:"not null;uniqueIndex:idx_acme_account" json:"caDirUrl"`
+	PrivateKey   string    `gorm:"not null" json:"-"` // PEM 格式的账户私钥
+	Registration string    `gorm:"null" json:"-"`     // 注册信息（JSON）
+	URI          string    `gorm:"null" json:"uri"`   // 账户地址
+	EABKeyId     string    `gorm:"null" json:"-"`     // 注册前保存的 External Account Binding Key ID，注册成功后清除
+	EABHmacKey   string    `gorm:"null" json:"-"`     // 注册前保存的 External Account Binding HMAC Key，注册成功后清除
+	CreateTime   time.Time `gorm:"null" json:"createTime"`
+	UpdateTime   time.Time `gorm:"null" json:"updateTime"`
+}
+
 // MatchesDomain 方法检查给定的域名是否与证书匹配
 func (c *Certificate) MatchesDomain(domain string) bool {
 	// 检查 Common Name 是否匹配
@@ -0,0 +1,51 @@
+package models
+
+import "testing"
+
+func TestChallengeClientAllowDomain(t *testing.T) {
+	client := ChallengeClient{Domains: "a.com,b.net"}
+	for domain, allowed := range map[string]bool{
+		"a.com":      true,
+		"*.a.com":    true,
+		"www.A.com.": true,
+		"b.net":      true,
+		"xa.com":     false,
+		"a.com.cn":   false,
+		"c.org":      false,
+	} {
+		if client.AllowDomain(domain) != allowed {
+			t.Errorf("AllowDomain(%q) = %v, want %v", domain, !allowed, allowed)
+		}
+	}
+}
+
+func TestChallengeClientAllowIP(t *testing.T) {
+	if !(&ChallengeClient{}).AllowIP("203.0.113.1") {
+		t.Error("未设置 AllowFrom 时应允许所有 IP")
+	}
+	client := ChallengeClient{AllowFrom: "192.0.2.0/24, 2001:db8::1"}
+	for ip, allowed := range map[string]bool{
+		"192.0.2.10":  true,
+		"2001:db8::1": true,
+		"2001:db8::2": false,
+		"198.51.100.": false,
+	} {
+		if client.AllowIP(ip) != allowed {
+			t.Errorf("AllowIP(%q) = %v, want %v", ip, !allowed, allowed)
+		}
+	}
+}
+
+func TestDDNSClientAllowHostname(t *testing.T) {
+	client := DDNSClient{Hostnames: "box1.a.com, Box2.a.com"}
+	for hostname, allowed := range map[string]bool{
+		"box1.a.com":     true,
+		"box2.a.com":     true,
+		"sub.box1.a.com": false,
+		"a.com":          false,
+	} {
+		if client.AllowHostname(hostname) != allowed {
+			t.Errorf("AllowHostname(%q) = %v, want %v", hostname, !allowed, allowed)
+		}
+	}
+}
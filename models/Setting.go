@@ -13,15 +13,27 @@ type Account struct {
 	Type            string `toml:"Type" json:"type"`
 }
 
+// FastConfig 快速解析配置，自身为默认解析池，Pools 中为通过 /fast/:pool 选择的其他解析池
 type FastConfig struct {
-	UseAccount string `toml:"UseAccount" json:"useAccount"`
-	DomainId   string `toml:"DomainId" json:"domainId"`
-	DomainName string `toml:"DomainName" json:"domainName"`
-	NameStrata string `toml:"NameStrata" json:"nameStrata"`
-	IdLength   int    `toml:"IdLength" json:"idLength"`
-	StartId    int    `toml:"StartId" json:"startId"`
-	DataPath   string `toml:"DataPath" json:"dataPath"`
-	AccessSalt string `toml:"AccessSalt" json:"accessSalt"`
+	Name         string     `toml:"Name" json:"name"` // 解析池名称，默认解析池为空
+	UseAccount   string     `toml:"UseAccount" json:"useAccount"`
+	DomainId     string     `toml:"DomainId" json:"domainId"`
+	DomainName   string     `toml:"DomainName" json:"domainName"`
+	NameStrata   string     `toml:"NameStrata" json:"nameStrata"`
+	IdLength     int        `toml:"IdLength" json:"idLength"`
+	StartId      int        `toml:"StartId" json:"startId"`
+	EndId        int        `toml:"EndId" json:"endId"` // 解析结束编号，为 0 时不限制
+	DataPath     string     `toml:"DataPath" json:"dataPath"`
+	AccessSalt   string     `toml:"AccessSalt" json:"accessSalt"`
+	LabelPattern string     `toml:"LabelPattern" json:"labelPattern"` // 允许的自定义主机记录（正则），为空时只允许单个 DNS 标签
+	Zones        []FastZone `toml:"zones" json:"zones"`               // 额外可选的域名
+	TTL          int64      `toml:"TTL" json:"ttl"`                   // 新建记录的 TTL，为 0 时使用云服务商默认值
+	// Token 生命周期
+	ExpireDays   int `toml:"ExpireDays" json:"expireDays"`     // 新建 Token 的有效天数，为 0 时不过期
+	InactiveDays int `toml:"InactiveDays" json:"inactiveDays"` // 新建 Token 超过多少天未更新视为失效，为 0 时不限制
+	ReapInterval int `toml:"ReapInterval" json:"reapInterval"` // 清理失效 Token 的检查周期（分钟），只对默认解析池生效
+
+	Pools []FastConfig `toml:"pools" json:"pools"` // 其他解析池
 }
 
 type BaseConfig struct {
@@ -30,28 +42,116 @@ type BaseConfig struct {
 	AccessKeyId     string `toml:"AccessKeyId"`
 	AccessKeySecret string `toml:"AccessKeySecret"`
 	RedisPoint      string `toml:"RedisPoint" json:"redisPoint"`
+
+	TrustedProxies  []string `toml:"TrustedProxies" json:"trustedProxies"`   // 受信任的反向代理 IP 或 IP 段，为空时不信任任何代理请求头
+	TrustedPlatform string   `toml:"TrustedPlatform" json:"trustedPlatform"` // 保存客户端 IP 的请求头，可选 cloudflare | X-Real-IP 或其他请求头名称
+	ForwardedDepth  int      `toml:"ForwardedDepth" json:"forwardedDepth"`   // X-Forwarded-For 中的代理层数，为 0 时从右向左跳过受信任的代理
 }
 
 type CertificateConfig struct {
-	EmailList       []string `toml:"EmailList"`
-	MaxRequest      int      `toml:"MaxRequest"`
-	SavePath        string   `toml:"SavePath"`
-	ApplyAccount    string   `toml:"ApplyAccount"`
-	ApplyDomainId   string   `toml:"ApplyDomainId"`
-	ApplyDomainName string   `toml:"ApplyDomainName"`
-	ConcurrencyTask int      `toml:"ConcurrencyTask"`
+	EmailList          []string `toml:"EmailList"`
+	MaxRequest         int      `toml:"MaxRequest"`
+	SavePath           string   `toml:"SavePath"`
+	ApplyAccount       string   `toml:"ApplyAccount"`
+	ApplyDomainId      string   `toml:"ApplyDomainId"`
+	ApplyDomainName    string   `toml:"ApplyDomainName"`
+	CA                 string   `toml:"CA"`         // CA 名称或目录地址
+	CACertPath         string   `toml:"CACertPath"` // 私有 CA 根证书路径
+	EABKeyId           string   `toml:"EABKeyId"`   // External Account Binding Key ID
+	EABHmacKey         string   `toml:"EABHmacKey"` // External Account Binding HMAC Key
+	KeyType            string   `toml:"KeyType"`    // 证书密钥类型 RSA2048 | RSA4096 | EC256 | EC384
+	ConcurrencyTask    int      `toml:"ConcurrencyTask"`
+	TaskQueue          string   `toml:"TaskQueue"`          // 默认投递的任务队列 critical | default | low
+	TaskMaxRetry       int      `toml:"TaskMaxRetry"`       // 任务失败最大重试次数
+	TaskTimeout        int      `toml:"TaskTimeout"`        // 单个任务超时时间（分钟）
+	AutoRenew          bool     `toml:"AutoRenew"`          // 是否开启自动续期
+	RenewBeforeDays    int      `toml:"RenewBeforeDays"`    // 到期前多少天进行续期
+	RenewCron          string   `toml:"RenewCron"`          // 续期检查周期（cron 表达式）
+	AllowDeployCommand bool     `toml:"AllowDeployCommand"` // 是否允许部署目标执行命令
+}
+
+// ChallengeDNSConfig 内置 DNS 服务配置，作为委托验证域名的权威服务器应答 TXT 验证记录
+type ChallengeDNSConfig struct {
+	Enable    bool   `toml:"Enable"`
+	Listen    string `toml:"Listen"`    // 监听地址，同时监听 UDP 与 TCP
+	Zone      string `toml:"Zone"`      // 委托给本服务的验证域名
+	NS        string `toml:"NS"`        // 本服务的 NS 域名
+	NSAddress string `toml:"NSAddress"` // NS 域名对应的公网 IP
+	TTL       uint32 `toml:"TTL"`       // 记录 TTL（秒）
+}
+
+// ExternalDNSConfig ExternalDNS webhook 配置，作为 ExternalDNS 的 webhook provider 管理账户中的解析记录
+type ExternalDNSConfig struct {
+	Enable         bool     `toml:"Enable"`
+	Listen         string   `toml:"Listen"`         // 监听地址，默认 127.0.0.1:8888
+	Accounts       []string `toml:"Accounts"`       // 管理的账户，为空时使用全部账户
+	DomainFilter   []string `toml:"DomainFilter"`   // 管理的域名（包含子域名），为空时不限制
+	ExcludeDomains []string `toml:"ExcludeDomains"` // 排除的域名（包含子域名）
+	DefaultTTL     int64    `toml:"DefaultTTL"`     // 未指定 TTL 时使用的默认值
+}
+
+// NotifyChannel 通知渠道
+type NotifyChannel struct {
+	Type     string   `toml:"Type" json:"type"`         // webhook | telegram | dingtalk | wecom | feishu | smtp
+	Url      string   `toml:"Url" json:"url"`           // webhook 或机器人地址
+	Secret   string   `toml:"Secret" json:"secret"`     // 钉钉、飞书机器人的加签密钥
+	BotToken string   `toml:"BotToken" json:"botToken"` // Telegram 机器人 Token
+	ChatId   string   `toml:"ChatId" json:"chatId"`     // Telegram 会话ID
+	Host     string   `toml:"Host" json:"host"`         // SMTP 服务器
+	Port     int      `toml:"Port" json:"port"`         // SMTP 端口，465 时使用 TLS 连接
+	Username string   `toml:"Username" json:"username"` // SMTP 用户名
+	Password string   `toml:"Password" json:"password"` // SMTP 密码
+	From     string   `toml:"From" json:"from"`         // 发件人，为空时使用用户名
+	To       []string `toml:"To" json:"to"`             // 收件人
+	Events   []string `toml:"Events" json:"events"`     // 通知的事件 change | inactive，为空时通知全部事件
+}
+
+// NotifyConfig 快速解析通知配置
+type NotifyConfig struct {
+	InactiveHours int             `toml:"InactiveHours" json:"inactiveHours"` // 超过多少小时未上报时通知，为 0 时不通知
+	Channels      []NotifyChannel `toml:"channels" json:"channels"`
+}
+
+// IPEchoConfig 公网 IP 查询接口配置
+type IPEchoConfig struct {
+	RateLimit  float64 `toml:"RateLimit" json:"rateLimit"`   // 每个 IP 每秒允许的请求数，为 0 时不限制
+	Burst      int     `toml:"Burst" json:"burst"`           // 允许的突发请求数
+	IPv6Prefix int     `toml:"IPv6Prefix" json:"ipv6Prefix"` // IPv6 地址按该前缀长度合并限流，默认 64
+	MaxClients int     `toml:"MaxClients" json:"maxClients"` // 同时记录的客户端数量上限，超出时拒绝新客户端，默认 10000
+}
+
+// ClientConfig DDNS 客户端配置，使用 client 子命令运行，与服务端配置分开保存
+type ClientConfig struct {
+	Server     string `toml:"Server" json:"server"`         // DomainSprite 地址
+	Token      string `toml:"Token" json:"token"`           // 快速解析 Token
+	Pool       string `toml:"Pool" json:"pool"`             // 解析池，默认解析池为空
+	IPv4       string `toml:"IPv4" json:"ipv4"`             // IPv4 获取方式 echo | interface | off
+	IPv6       string `toml:"IPv6" json:"ipv6"`             // IPv6 获取方式 echo | interface | off
+	EchoUrl    string `toml:"EchoUrl" json:"echoUrl"`       // 以纯文本返回请求来源 IP 的地址
+	Interface  string `toml:"Interface" json:"interface"`   // 从网卡获取地址时使用的网卡，为空时查找所有网卡
+	Interval   int    `toml:"Interval" json:"interval"`     // 检查周期（秒）
+	ForceHours int    `toml:"ForceHours" json:"forceHours"` // 地址未变化时至少多少小时上报一次，避免被判定为长时间未上报
+	StatePath  string `toml:"StatePath" json:"statePath"`   // 本地状态缓存文件
 }
 
 type Config struct {
-	BaseConfig  BaseConfig        `toml:"baseConfig" json:"baseConfig"`
-	Certificate CertificateConfig `toml:"certificateConfig" json:"certificateConfig"`
-	FastConfig  FastConfig        `toml:"fastConfig" json:"fastConfig"`
-	Accounts    []Account         `toml:"account" json:"account"`
+	BaseConfig   BaseConfig         `toml:"baseConfig" json:"baseConfig"`
+	Certificate  CertificateConfig  `toml:"certificateConfig" json:"certificateConfig"`
+	ChallengeDNS ChallengeDNSConfig `toml:"challengeDNS" json:"challengeDNS"`
+	ExternalDNS  ExternalDNSConfig  `toml:"externalDNS" json:"externalDNS"`
+	FastConfig   FastConfig         `toml:"fastConfig" json:"fastConfig"`
+	Notify       NotifyConfig       `toml:"notify" json:"notify"`
+	IPEcho       IPEchoConfig       `toml:"ipEcho" json:"ipEcho"`
+	Accounts     []Account          `toml:"account" json:"account"`
 }
 
 var AccountConfig Config
 
 func init() {
+	// 配置文件不存在时由 utils.InitConfig 生成，客户端模式不需要服务端配置
+	if _, err := os.Stat("config.toml"); os.IsNotExist(err) {
+		return
+	}
 	if _, err := toml.DecodeFile("config.toml", &AccountConfig); err != nil {
 		fmt.Println("Error decoding TOML:", err)
 		return
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/registration"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// 预置的 CA 目录地址
+var CADirectoryList = map[string]string{
+	"letsencrypt":         lego.LEDirectoryProduction,
+	"letsencrypt-staging": lego.LEDirectoryStaging,
+	"zerossl":             "https://acme.zerossl.com/v2/DV90",
+	"google":              "https://dv.acme-v02.api.pki.goog/directory",
+	"google-staging":      "https://dv.acme-v02.test-api.pki.goog/directory",
+}
+
+// 必须使用 External Account Binding 的 CA，key 为目录地址
+var eabRequiredCA = map[string]bool{
+	CADirectoryList["zerossl"]:        true,
+	CADirectoryList["google"]:         true,
+	CADirectoryList["google-staging"]: true,
+}
+
+// 支持的证书密钥类型
+var KeyTypeList = map[string]certcrypto.KeyType{
+	"RSA2048": certcrypto.RSA2048,
+	"RSA4096": certcrypto.RSA4096,
+	"EC256":   certcrypto.EC256,
+	"EC384":   certcrypto.EC384,
+}
+
+// AcmeOptions 证书申请使用的 CA 与密钥参数
+// EAB 只在注册账户时使用，不写入任务负载，请求中提交的 EAB 保存在账户记录中
+type AcmeOptions struct {
+	CADirURL   string `json:"caDirUrl"` // CA 目录地址
+	KeyType    string `json:"keyType"`  // 证书密钥类型
+	Email      string `json:"email"`    // 申请使用的 ACME 账户邮箱，为空时从账户池轮换
+	EABKeyId   string `json:"-"`        // External Account Binding Key ID
+	EABHmacKey string `json:"-"`        // External Account Binding HMAC Key
+}
+
+// getCADirURL 将 CA 名称或目录地址解析为目录地址
+func getCADirURL(ca string) (string, error) {
+	if dirURL, ok := CADirectoryList[strings.ToLower(ca)]; ok {
+		return dirURL, nil
+	}
+	if strings.HasPrefix(ca, "https://") || strings.HasPrefix(ca, "http://") {
+		return ca, nil
+	}
+	return "", fmt.Errorf("未知的 CA: %s", ca)
+}
+
+// NewAcmeOptions 根据请求参数生成申请参数，未指定的参数使用配置文件中的默认值，配置文件中的 EAB 在注册账户时读取
+func NewAcmeOptions(ca, keyType, eabKeyId, eabHmacKey string) (AcmeOptions, error) {
+	config := AccountConfig.Certificate
+	if config.CA == "" {
+		config.CA = "letsencrypt"
+	}
+	if ca == "" {
+		ca = config.CA
+	}
+	if keyType == "" {
+		keyType = config.KeyType
+	}
+	if keyType == "" {
+		keyType = "RSA2048"
+	}
+	if _, ok := KeyTypeList[keyType]; !ok {
+		return AcmeOptions{}, fmt.Errorf("不支持的密钥类型: %s", keyType)
+	}
+	dirURL, err := getCADirURL(ca)
+	if err != nil {
+		return AcmeOptions{}, err
+	}
+	if (eabKeyId == "") != (eabHmacKey == "") {
+		return AcmeOptions{}, errors.New("EAB Key ID 与 HMAC Key 需要同时提供")
+	}
+	return AcmeOptions{
+		CADirURL:   dirURL,
+		KeyType:    keyType,
+		EABKeyId:   eabKeyId,
+		EABHmacKey: eabHmacKey,
+	}, nil
+}
+
+// ConfigEAB 获取配置文件中的 EAB，EAB 与 CA 绑定，仅当目录地址与配置文件中的 CA 一致时返回
+func ConfigEAB(dirURL string) (string, string) {
+	config := AccountConfig.Certificate
+	if config.CA == "" {
+		config.CA = "letsencrypt"
+	}
+	if configDirURL, _ := getCADirURL(config.CA); configDirURL != dirURL {
+		return "", ""
+	}
+	return config.EABKeyId, config.EABHmacKey
+}
+
+// CheckEAB 检查注册账户所需的 EAB，按目录地址判断 CA 是否必须使用 EAB
+func (o AcmeOptions) CheckEAB() error {
+	if o.EABKeyId == "" && eabRequiredCA[o.CADirURL] {
+		return fmt.Errorf("CA %s 需要提供 EAB Key ID 与 HMAC Key", o.CADirURL)
+	}
+	return nil
+}
+
+// GetKeyType 获取 lego 使用的密钥类型
+func (o AcmeOptions) GetKeyType() certcrypto.KeyType {
+	if keyType, ok := KeyTypeList[o.KeyType]; ok {
+		return keyType
+	}
+	return certcrypto.RSA2048
+}
+
+// NewLegoConfig 根据申请参数生成 lego 配置
+func (o AcmeOptions) NewLegoConfig(user registration.User) (*lego.Config, error) {
+	config := lego.NewConfig(user)
+	config.CADirURL = o.CADirURL
+	if config.CADirURL == "" {
+		config.CADirURL = lego.LEDirectoryProduction
+	}
+	config.Certificate.KeyType = o.GetKeyType()
+	// 私有 CA（step-ca、Pebble 等）需要信任其根证书
+	if AccountConfig.Certificate.CACertPath != "" {
+		caCert, err := os.ReadFile(AccountConfig.Certificate.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取 CA 根证书失败: %v", err)
+		}
+		certPool, err := x509.SystemCertPool()
+		if err != nil {
+			certPool = x509.NewCertPool()
+		}
+		if !certPool.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("CA 根证书格式错误")
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: certPool}
+		config.HTTPClient.Transport = transport
+	}
+	return config, nil
+}
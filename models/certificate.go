@@ -3,14 +3,10 @@ package models
 import (
 	"DDNSServer/utils"
 	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
 	"encoding/json"
 	"fmt"
-	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
 	"github.com/go-acme/lego/v4/challenge/dns01"
-	"github.com/go-acme/lego/v4/lego"
 	"github.com/go-acme/lego/v4/registration"
 	"os"
 	"path/filepath"
@@ -18,8 +14,6 @@ import (
 	"time"
 )
 
-var EmailIndex = 0
-
 type Resource struct {
 	certificate.Resource
 	PrivateKeyPath  string `json:"privateKeyPath"`  // 私钥文件路径
@@ -29,138 +23,159 @@ type Resource struct {
 	SavePath        string `json:"savePath"`
 }
 
-type ClientManager struct {
-	Client       *lego.Client
-	Resource     *registration.Resource
-	RequestCount int
-	MaxRequests  int
+// AcmeUser ACME 账户，实现 registration.User 接口
+type AcmeUser struct {
 	Email        string
-	privateKey   crypto.PrivateKey // 新增字段存储私钥
+	Registration *registration.Resource
+	Key          crypto.PrivateKey
 }
 
-func (m *ClientManager) GetEmail() string {
-	return m.Email
+func (u *AcmeUser) GetEmail() string {
+	return u.Email
 }
-func (m *ClientManager) GetRegistration() *registration.Resource {
-	return m.Resource
+func (u *AcmeUser) GetRegistration() *registration.Resource {
+	return u.Registration
 }
-func (m *ClientManager) GetPrivateKey() crypto.PrivateKey {
-	if m.privateKey == nil {
-		// 如果私钥不存在，生成一个新的 RSA 私钥
-		key, err := rsa.GenerateKey(rand.Reader, 2048)
-		if err != nil {
-			fmt.Println("生成私钥失败:", err)
-		}
-		m.privateKey = key
+func (u *AcmeUser) GetPrivateKey() crypto.PrivateKey {
+	return u.Key
+}
+
+// ZoneResolver 查找验证域名所属的主域名及其账户
+type ZoneResolver interface {
+	// Resolve 返回域名所属主域名对应账户的 RecordProvider，域名不在数据库中时返回 false
+	Resolve(domain string) (RecordProvider, DomainInfo, bool)
+}
+
+type CertificatePrivate struct {
+	provider RecordProvider // 第三方域名（CNAME 委托）写入验证记录使用的账户
+	zones    []DomainInfo   // 申请证书的域名所属的主域名列表
+	Resolver ZoneResolver   // 按域名查找账户，未设置时仅使用 provider 与 zones
+	SavePath string
+}
+
+// NewChallengeRecordProvider 创建只用于写入验证记录的 Provider（不保存证书），供外部 ACME 客户端使用
+// recordProvider 为第三方域名写入验证记录使用的账户，开启内置 DNS 服务时可以为 nil
+func NewChallengeRecordProvider(recordProvider RecordProvider, resolver ZoneResolver) *CertificatePrivate {
+	return &CertificatePrivate{
+		provider: recordProvider,
+		Resolver: resolver,
 	}
-	return m.privateKey
 }
 
-func (m *ClientManager) GetClient() (*lego.Client, error) {
-	if m.Client == nil || m.RequestCount >= m.MaxRequests {
-		// 获取一个邮箱
-		m.Email = AccountConfig.Certificate.EmailList[EmailIndex]
-		m.MaxRequests = AccountConfig.Certificate.MaxRequest
-		// 创建新客户端
-		config := lego.NewConfig(m)
-		config.CADirURL = lego.LEDirectoryProduction    // 使用 Let's Encrypt 生产环境
-		config.Certificate.KeyType = certcrypto.RSA2048 // 设置默认密钥类型
-		client, err := lego.NewClient(config)
-		if err != nil {
-			return nil, fmt.Errorf("创建 ACME 客户端失败: %v", err)
+// getChallengeRecord 获取验证记录写入的账户与位置，无法找到所属账户的域名按照第三方申请，写入其hash解析
+func (p *CertificatePrivate) getChallengeRecord(domain, fqdn string) (RecordProvider, DomainInfo, string) {
+	if p.Resolver != nil {
+		if provider, zone, ok := p.Resolver.Resolve(domain); ok {
+			// _acme-challenge 或 _acme-challenge.sub
+			return provider, zone, strings.TrimSuffix(fqdn, "."+zone.DomainName+".")
 		}
-		reg, err := client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
-		if err != nil {
-			return nil, fmt.Errorf("注册用户失败: %v", err)
-		}
-		m.Resource = reg
-		// 邮箱迭代
-		EmailIndex++
-		if EmailIndex >= len(AccountConfig.Certificate.EmailList) {
-			EmailIndex = 0
-		}
-		m.Client = client
-		m.RequestCount = 0 // 重置计数
+	} else if zone, ok := FindZone(domain, p.zones); ok && zone.AccountName != "" && p.provider.GetAccountInfo().Name == zone.AccountName {
+		return p.provider, zone, strings.TrimSuffix(fqdn, "."+zone.DomainName+".")
 	}
-	return m.Client, nil
+	return p.getDelegateRecord(utils.HashString(domain))
 }
 
-type CertificatePrivate struct {
-	provider   RecordProvider
-	domain     DomainInfo
-	SavePath   string
-	SelfDomain bool
+// getDelegateRecord 获取第三方域名（CNAME 委托）验证记录写入的账户与位置
+// 开启内置 DNS 服务时返回的 RecordProvider 为 nil，验证记录写入内存
+func (p *CertificatePrivate) getDelegateRecord(recordName string) (RecordProvider, DomainInfo, string) {
+	if ChallengeDNSEnabled() {
+		return nil, DomainInfo{Domains: Domains{DomainName: GetChallengeDomainName()}}, recordName
+	}
+	return p.provider, DomainInfo{
+		Domains: Domains{
+			Id:         AccountConfig.Certificate.ApplyDomainId,
+			DomainName: AccountConfig.Certificate.ApplyDomainName,
+		},
+	}, recordName
 }
 
 // Present 添加 TXT 记录以完成 DNS-01 挑战
 func (p *CertificatePrivate) Present(domain, token, keyAuth string) error {
 	// 解析挑战信息
 	fqdn := dns01.GetChallengeInfo(domain, keyAuth)
-	rr := strings.TrimSuffix(fqdn.FQDN, "."+domain+".") // _acme-challenge
-	// 构造 TXT 记录
-	record := RecordInfo{}
+	return p.PresentRecord(domain, fqdn.FQDN, fqdn.Value)
+}
 
-	// 判断该域名是否存在数据库中，不存在按照第三方申请，获取其hash进行解析
-	if !p.SelfDomain || p.provider.GetAccountInfo().Name != p.domain.AccountName {
-		record = RecordInfo{
-			DomainId:      AccountConfig.Certificate.ApplyDomainId,
-			DomainName:    AccountConfig.Certificate.ApplyDomainName,
-			RecordName:    utils.HashString(domain),
-			RecordType:    "TXT",
-			RecordContent: fqdn.Value,
-		}
-	} else {
-		record = RecordInfo{
-			DomainId:      p.domain.Id,
-			DomainName:    domain,
-			RecordName:    rr,
-			RecordType:    "TXT",
-			RecordContent: fqdn.Value,
+// CleanUp 删除 TXT 记录
+func (p *CertificatePrivate) CleanUp(domain, token, keyAuth string) error {
+	// 解析挑战信息
+	fqdn := dns01.GetChallengeInfo(domain, keyAuth)
+	return p.CleanUpRecord(domain, fqdn.FQDN, fqdn.Value)
+}
+
+// PresentRecord 为域名添加验证 TXT 记录，fqdn 为 _acme-challenge 完整域名
+func (p *CertificatePrivate) PresentRecord(domain, fqdn, value string) error {
+	provider, zone, recordName := p.getChallengeRecord(domain, fqdn)
+	return addChallengeRecord(provider, zone, recordName, value)
+}
+
+// CleanUpRecord 删除域名的验证 TXT 记录
+func (p *CertificatePrivate) CleanUpRecord(domain, fqdn, value string) error {
+	provider, zone, recordName := p.getChallengeRecord(domain, fqdn)
+	return removeChallengeRecord(provider, zone, recordName, value)
+}
+
+// PresentDelegate 在第三方验证域名下添加指定名称的 TXT 记录
+func (p *CertificatePrivate) PresentDelegate(recordName, value string) error {
+	provider, zone, recordName := p.getDelegateRecord(recordName)
+	return addChallengeRecord(provider, zone, recordName, value)
+}
+
+// CleanUpDelegate 删除第三方验证域名下指定名称的 TXT 记录
+func (p *CertificatePrivate) CleanUpDelegate(recordName, value string) error {
+	provider, zone, recordName := p.getDelegateRecord(recordName)
+	return removeChallengeRecord(provider, zone, recordName, value)
+}
+
+// addChallengeRecord 添加 TXT 记录，provider 为 nil 时写入内置 DNS 服务
+func addChallengeRecord(provider RecordProvider, zone DomainInfo, recordName, value string) error {
+	if provider == nil {
+		if !ChallengeDNSEnabled() {
+			return fmt.Errorf("未配置第三方域名验证使用的账户")
 		}
+		ChallengeTXT.Add(recordName+"."+zone.DomainName, value)
+		return nil
+	}
+	// 构造 TXT 记录
+	record := RecordInfo{
+		DomainId:      zone.Id,
+		DomainName:    zone.DomainName,
+		RecordName:    recordName,
+		RecordType:    "TXT",
+		RecordContent: value,
 	}
 
 	// 添加记录
-	_, err := p.provider.AddRecord(record)
+	_, err := provider.AddRecord(record)
 	if err != nil {
 		return fmt.Errorf("添加 TXT 记录失败: %v", err)
 	}
 	return nil
 }
 
-// CleanUp 删除 TXT 记录
-func (p *CertificatePrivate) CleanUp(domain, token, keyAuth string) error {
-	// 解析挑战信息
-	fqdn := dns01.GetChallengeInfo(domain, keyAuth)
-	rr := strings.TrimSuffix(fqdn.FQDN, "."+domain+".") // _acme-challenge
+// removeChallengeRecord 删除 TXT 记录，provider 为 nil 时从内置 DNS 服务中删除
+func removeChallengeRecord(provider RecordProvider, zone DomainInfo, recordName, value string) error {
+	if provider == nil {
+		ChallengeTXT.Remove(recordName+"."+zone.DomainName, value)
+		return nil
+	}
 	// 构造 TXT 记录
-	search := DNSSearch{}
-
-	// 判断该域名是否存在数据库中，不存在按照第三方申请，获取其hash进行解析
-	if !p.SelfDomain || p.provider.GetAccountInfo().Name != p.domain.AccountName {
-		search = DNSSearch{
-			DomainId:    AccountConfig.Certificate.ApplyDomainId,
-			DomainName:  AccountConfig.Certificate.ApplyDomainName,
-			RRKeyWord:   utils.HashString(domain),
-			TypeKeyWord: "TXT",
-		}
-	} else {
-		search = DNSSearch{
-			DomainId:    p.domain.Id,
-			DomainName:  domain,
-			RRKeyWord:   rr,
-			TypeKeyWord: "TXT",
-		}
+	search := DNSSearch{
+		DomainId:    zone.Id,
+		DomainName:  zone.DomainName,
+		RRKeyWord:   recordName,
+		TypeKeyWord: "TXT",
 	}
 
-	records, err := p.provider.GetRecordList(search)
+	records, err := provider.GetRecordList(search)
 	if err != nil {
 		return fmt.Errorf("获取记录列表失败: %v", err)
 	}
 
 	// 删除匹配的记录
 	for _, record := range records.Records {
-		if fqdn.Value == record.RecordContent {
-			_, err := p.provider.DeleteRecord(domain, record.Id)
+		if value == record.RecordContent {
+			_, err := provider.DeleteRecord(zone.DomainName, record.Id)
 			if err != nil {
 				return fmt.Errorf("删除 TXT 记录失败: %v", err)
 			}
@@ -269,17 +284,17 @@ func (p *CertificatePrivate) LoadResource() (*Resource, error) {
 	return &resource, nil
 }
 
-func NewProvider(recordProvider RecordProvider, domain DomainInfo) *CertificatePrivate {
+func NewProvider(recordProvider RecordProvider, zones []DomainInfo) *CertificatePrivate {
 	nowTime := time.Now()
 	SavePath := filepath.Join(
 		AccountConfig.Certificate.SavePath,
 		nowTime.Format("2006"),
 		nowTime.Format("01-02"),
-		domain.DomainName,
+		zones[0].DomainName,
 	)
 	return &CertificatePrivate{
 		provider: recordProvider,
-		domain:   domain,
+		zones:    zones,
 		SavePath: SavePath,
 	}
 }
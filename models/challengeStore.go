@@ -0,0 +1,72 @@
+package models
+
+import (
+	"strings"
+	"sync"
+)
+
+// ChallengeDNSEnabled 是否使用内置 DNS 服务应答第三方域名的验证记录
+func ChallengeDNSEnabled() bool {
+	return AccountConfig.ChallengeDNS.Enable && AccountConfig.ChallengeDNS.Zone != ""
+}
+
+// GetChallengeDomainName 获取第三方域名 CNAME 委托的目标域名
+func GetChallengeDomainName() string {
+	if ChallengeDNSEnabled() {
+		return CanonicalDNSName(AccountConfig.ChallengeDNS.Zone)
+	}
+	return AccountConfig.Certificate.ApplyDomainName
+}
+
+// CanonicalDNSName 将域名转换为小写且不带末尾点的形式
+func CanonicalDNSName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// challengeTXTStore 内存中的 TXT 验证记录，同一名称可以同时存在多个值（例如主域名与通配符）
+type challengeTXTStore struct {
+	mu      sync.RWMutex
+	records map[string][]string
+}
+
+// ChallengeTXT 内置 DNS 服务使用的 TXT 验证记录
+var ChallengeTXT = &challengeTXTStore{records: map[string][]string{}}
+
+// Add 添加 TXT 记录
+func (s *challengeTXTStore) Add(name, value string) {
+	name = CanonicalDNSName(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range s.records[name] {
+		if v == value {
+			return
+		}
+	}
+	s.records[name] = append(s.records[name], value)
+}
+
+// Remove 删除 TXT 记录
+func (s *challengeTXTStore) Remove(name, value string) {
+	name = CanonicalDNSName(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := s.records[name]
+	for i, v := range values {
+		if v == value {
+			values = append(values[:i:i], values[i+1:]...)
+			break
+		}
+	}
+	if len(values) == 0 {
+		delete(s.records, name)
+		return
+	}
+	s.records[name] = values
+}
+
+// Get 获取 TXT 记录
+func (s *challengeTXTStore) Get(name string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.records[CanonicalDNSName(name)]...)
+}
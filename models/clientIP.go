@@ -0,0 +1,80 @@
+package models
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// platformHeaders 常见平台保存客户端 IP 的请求头
+var platformHeaders = map[string]string{
+	"cloudflare": "CF-Connecting-IP",
+	"x-real-ip":  "X-Real-IP",
+}
+
+// ipInList 判断 IP 是否匹配列表中的 IP 或 IP 段
+func ipInList(addr net.IP, list []string) bool {
+	for _, cidr := range list {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			if addr.Equal(net.ParseIP(cidr)) {
+				return true
+			}
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil && ipNet.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTrustedProxy 判断 IP 是否为受信任的反向代理
+func (c BaseConfig) IsTrustedProxy(ip string) bool {
+	addr := net.ParseIP(ip)
+	return addr != nil && ipInList(addr, c.TrustedProxies)
+}
+
+// ClientIP 获取客户端真实 IP，只有直接连接的地址是受信任的反向代理时才读取请求头
+// 优先读取 TrustedPlatform 对应的请求头，其次按 ForwardedDepth 读取 X-Forwarded-For
+func (c BaseConfig) ClientIP(remoteIP string, header http.Header) string {
+	if !c.IsTrustedProxy(remoteIP) {
+		return remoteIP
+	}
+	if c.TrustedPlatform != "" {
+		name, ok := platformHeaders[strings.ToLower(c.TrustedPlatform)]
+		if !ok {
+			name = c.TrustedPlatform
+		}
+		if ip := strings.TrimSpace(header.Get(name)); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+	// 代理链从左到右依次为客户端、各级代理，最后是直接连接的地址
+	var chain []string
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, ip := range strings.Split(value, ",") {
+			if ip = strings.TrimSpace(ip); net.ParseIP(ip) != nil {
+				chain = append(chain, ip)
+			}
+		}
+	}
+	if len(chain) == 0 {
+		return remoteIP
+	}
+	chain = append(chain, remoteIP)
+	if c.ForwardedDepth > 0 {
+		// 固定层数的代理，跳过最右侧的 ForwardedDepth 个地址，地址不足时最左侧的地址可能由客户端伪造，使用直接连接的地址
+		if len(chain) <= c.ForwardedDepth {
+			return remoteIP
+		}
+		return chain[len(chain)-1-c.ForwardedDepth]
+	}
+	// 从右向左跳过受信任的代理
+	for i := len(chain) - 1; i > 0; i-- {
+		if !c.IsTrustedProxy(chain[i]) {
+			return chain[i]
+		}
+	}
+	return chain[0]
+}
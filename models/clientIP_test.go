@@ -0,0 +1,30 @@
+package models
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.5, 10.0.0.2")
+	header.Set("CF-Connecting-IP", "198.51.100.9")
+	for _, test := range []struct {
+		name     string
+		config   BaseConfig
+		remoteIP string
+		want     string
+	}{
+		{"不信任代理", BaseConfig{}, "10.0.0.1", "10.0.0.1"},
+		{"非受信任代理", BaseConfig{TrustedProxies: []string{"10.0.0.0/8"}}, "192.0.2.1", "192.0.2.1"},
+		{"跳过受信任代理", BaseConfig{TrustedProxies: []string{"10.0.0.0/8"}}, "10.0.0.1", "203.0.113.5"},
+		{"代理层数", BaseConfig{TrustedProxies: []string{"10.0.0.0/8"}, ForwardedDepth: 3}, "10.0.0.1", "198.51.100.1"},
+		{"代理层数超出", BaseConfig{TrustedProxies: []string{"10.0.0.0/8"}, ForwardedDepth: 9}, "10.0.0.1", "10.0.0.1"},
+		{"Cloudflare", BaseConfig{TrustedProxies: []string{"10.0.0.1"}, TrustedPlatform: "cloudflare"}, "10.0.0.1", "198.51.100.9"},
+		{"请求头不存在", BaseConfig{TrustedProxies: []string{"10.0.0.1"}, TrustedPlatform: "X-Real-IP", ForwardedDepth: 1}, "10.0.0.1", "10.0.0.2"},
+	} {
+		if got := test.config.ClientIP(test.remoteIP, header); got != test.want {
+			t.Errorf("%s: ClientIP = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
@@ -1,89 +1,275 @@
 package models
 
 import (
+	"DDNSServer/utils"
 	"encoding/json"
-	"fmt"
+	"gorm.io/gorm"
 	"os"
-	"strconv"
+	"regexp"
+	"strings"
+	"time"
 )
 
-type FastData struct {
-	Token      string     `json:"token"`
-	RecordInfo RecordInfo `json:"recordInfo"`
+// defaultLabelPattern 默认允许的自定义主机记录，单个 DNS 标签
+const defaultLabelPattern = `^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`
+
+// FastZone 快速解析可使用的域名
+type FastZone struct {
+	UseAccount string `toml:"UseAccount" json:"useAccount"` // 使用的账户，为空时使用 fastConfig.UseAccount
+	DomainId   string `toml:"DomainId" json:"domainId"`
+	DomainName string `toml:"DomainName" json:"domainName"`
 }
 
-type FastDataJson struct {
-	DataList []FastData `json:"dataList"`
-	LastId   int        `json:"lastId"`
+// GetPool 根据名称获取解析池，name 为空时返回默认解析池
+func (c FastConfig) GetPool(name string) (FastConfig, bool) {
+	if name == "" {
+		c.Pools = nil
+		return c, true
+	}
+	for _, pool := range c.Pools {
+		if pool.Name == name {
+			pool.Pools = nil
+			return pool, true
+		}
+	}
+	return FastConfig{}, false
 }
 
-func (f *FastDataJson) SaveToJson(filePath string) error {
-	data, err := json.Marshal(f)
-	if err != nil {
-		return err
+// GetZone 获取快速解析使用的域名，domainName 为空时使用默认域名
+func (c FastConfig) GetZone(domainName string) (FastZone, bool) {
+	domainName = CanonicalDNSName(domainName)
+	defaultZone := FastZone{UseAccount: c.UseAccount, DomainId: c.DomainId, DomainName: c.DomainName}
+	if domainName == "" || domainName == CanonicalDNSName(c.DomainName) {
+		return defaultZone, true
 	}
+	for _, zone := range c.Zones {
+		if CanonicalDNSName(zone.DomainName) == domainName {
+			if zone.UseAccount == "" {
+				zone.UseAccount = c.UseAccount
+			}
+			return zone, true
+		}
+	}
+	return FastZone{}, false
+}
 
-	err = os.WriteFile(filePath, data, 0644)
-	if err != nil {
-		return err
+// ValidLabel 判断自定义主机记录是否符合 LabelPattern
+func (c FastConfig) ValidLabel(label string) bool {
+	pattern := c.LabelPattern
+	if pattern == "" {
+		pattern = defaultLabelPattern
 	}
+	matched, err := regexp.MatchString(pattern, label)
+	return err == nil && matched
+}
 
-	return nil
+// FastHost 可以通过快速解析 Token 更新的主机记录，包括 FastData 本身与其额外的 FastRecord
+type FastHost interface {
+	Zone() FastZone
+	Label() string // 主机记录
+	FQDN() string
+	Record(recordType string) *RecordInfo
 }
 
-func (f *FastDataJson) LoadFromJson(filePath string) error {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return err
+// FastResult 单个主机记录的更新结果
+type FastResult struct {
+	FQDN    string `json:"fqdn"`
+	Status  string `json:"status"` // good 已更新 | nochg 无变化 | fail 失败
+	Message string `json:"message,omitempty"`
+}
+
+// FastData 快速解析记录，一个 Token 对应同一主机记录下的 A / AAAA 记录，以及 Records 中的其他主机记录
+type FastData struct {
+	Id           int        `gorm:"primaryKey" json:"id"`
+	Pool         string     `gorm:"not null;default:'';index" json:"pool"`                  // 所属解析池，默认解析池为空
+	Token        string     `gorm:"-" json:"token,omitempty"`                               // Token 明文，只在创建与重新生成时返回
+	TokenHash    string     `gorm:"uniqueIndex" json:"-"`                                   // Token 哈希
+	TokenPrefix  string     `gorm:"null" json:"tokenPrefix"`                                // Token 前缀，便于识别
+	AccountName  string     `gorm:"null" json:"accountName"`                                // 使用的账户
+	DomainId     string     `gorm:"null" json:"domainId"`                                   // 主域名ID
+	DomainName   string     `gorm:"null;uniqueIndex:idx_fast_record" json:"domainName"`     // 主域名
+	RecordName   string     `gorm:"not null;uniqueIndex:idx_fast_record" json:"recordName"` // 主机记录
+	IPv4         string     `gorm:"column:ipv4;null;index" json:"-"`                        // A 记录值，用于按 IP 查找
+	IPv6         string     `gorm:"column:ipv6;null;index" json:"-"`                        // AAAA 记录值，用于按 IP 查找
+	RecordInfo   RecordInfo `gorm:"serializer:json" json:"recordInfo"`                      // A 记录
+	RecordInfoV6 RecordInfo `gorm:"serializer:json" json:"recordInfoV6"`                    // AAAA 记录，与 A 记录使用相同的主机记录
+	Name         string     `gorm:"null" json:"name"`                                       // 备注名称
+	ExpireTime   time.Time  `gorm:"null" json:"expireTime"`                                 // 过期时间，为空时不过期
+	InactiveDays int        `gorm:"null" json:"inactiveDays"`                               // 超过多少天未更新视为失效，为 0 时不限制
+	LastSeenTime time.Time  `gorm:"null" json:"lastSeenTime"`                               // 客户端最近一次更新时间
+	NotifiedTime time.Time  `gorm:"null" json:"-"`                                          // 最近一次发送未上报通知的时间
+	CreateTime   time.Time  `gorm:"null" json:"createTime"`
+	UpdateTime   time.Time  `gorm:"null" json:"updateTime"`
+
+	Records []FastRecord `gorm:"foreignKey:FastId" json:"records,omitempty"` // 同一 Token 管理的其他主机记录
+	Results []FastResult `gorm:"-" json:"results,omitempty"`                 // 本次更新每个主机记录的结果
+}
+
+// Expired 判断 Token 是否已过期或长时间未更新
+func (f *FastData) Expired(now time.Time) bool {
+	if !f.ExpireTime.IsZero() && now.After(f.ExpireTime) {
+		return true
+	}
+	if f.InactiveDays <= 0 {
+		return false
 	}
+	return now.After(f.LastActiveTime().AddDate(0, 0, f.InactiveDays))
+}
 
-	err = json.Unmarshal(data, f)
-	if err != nil {
-		return err
+// LastActiveTime 客户端最近一次更新时间，从未更新时使用创建时间
+func (f *FastData) LastActiveTime() time.Time {
+	if f.LastSeenTime.IsZero() {
+		return f.CreateTime
 	}
+	return f.LastSeenTime
+}
 
+// BeforeSave 保存前计算新 Token 的哈希，并同步用于查找的 IP 字段
+func (f *FastData) BeforeSave(*gorm.DB) error {
+	if f.Token != "" {
+		f.TokenHash = utils.HashToken(f.Token)
+		f.TokenPrefix = f.Token[:min(8, len(f.Token))]
+	}
+	f.IPv4 = f.RecordInfo.RecordContent
+	f.IPv6 = f.RecordInfoV6.RecordContent
+	f.UpdateTime = time.Now()
 	return nil
 }
 
-func (f *FastDataJson) GetInfoForIp(ip string) (FastData, bool) {
-	for _, data := range f.DataList {
-		if data.RecordInfo.RecordContent == ip {
-			return data, true
-		}
+// Zone 获取记录所在的域名
+func (f *FastData) Zone() FastZone {
+	return FastZone{UseAccount: f.AccountName, DomainId: f.DomainId, DomainName: f.DomainName}
+}
+
+// Label 获取主机记录
+func (f *FastData) Label() string {
+	return f.RecordName
+}
+
+// FQDN 获取完整域名
+func (f *FastData) FQDN() string {
+	return fastFQDN(f.RecordName, f.DomainName)
+}
+
+// Hosts 获取 Token 管理的全部主机记录，第一个为 FastData 本身
+func (f *FastData) Hosts() []FastHost {
+	hosts := []FastHost{f}
+	for i := range f.Records {
+		hosts = append(hosts, &f.Records[i])
 	}
-	return FastData{}, false
+	return hosts
 }
 
-func (f *FastDataJson) GetInfoForToken(token string) (*FastData, bool) {
-	for i, data := range f.DataList {
-		if data.Token == token {
-			return &f.DataList[i], true
-		}
+// fastFQDN 拼接完整域名，Cloudflare 的记录名称本身就是完整域名，@ 表示主域名
+func fastFQDN(recordName, domainName string) string {
+	recordName = CanonicalDNSName(recordName)
+	domainName = CanonicalDNSName(domainName)
+	if recordName == "@" || recordName == domainName {
+		return domainName
+	}
+	if strings.HasSuffix(recordName, "."+domainName) {
+		return recordName
 	}
-	return &FastData{}, false
+	return recordName + "." + domainName
 }
 
-func GetFastData(filePath string) (FastDataJson, error) {
-	// 检查文件是否存在
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		// 使用 os.Create 创建文件，如果文件已存在会清空文件内容
-		file, err := os.Create(filePath)
-		if err != nil {
-			fmt.Println("Error creating file:", err)
-		}
-		defer file.Close() // 确保文件在函数结束时关闭
+// Record 获取指定类型的记录，A 以外的类型都视为 AAAA
+func (f *FastData) Record(recordType string) *RecordInfo {
+	if recordType == "A" {
+		return &f.RecordInfo
+	}
+	return &f.RecordInfoV6
+}
 
-		// 写入内容到文件
-		_, err = file.WriteString("{\"lastId\": " + strconv.Itoa(AccountConfig.FastConfig.StartId) + "}")
-		if err != nil {
-			fmt.Println("Error writing to file:", err)
-		}
+// FastRecord 快速解析 Token 管理的其他主机记录，可以位于不同的域名与账户下，与 FastData 使用相同的地址更新
+type FastRecord struct {
+	Id           int        `gorm:"primaryKey" json:"id"`
+	FastId       int        `gorm:"not null;index" json:"fastId"`                         // 快速解析记录ID
+	AccountName  string     `gorm:"null" json:"accountName"`                              // 使用的账户
+	DomainId     string     `gorm:"null" json:"domainId"`                                 // 主域名ID
+	DomainName   string     `gorm:"null;uniqueIndex:idx_fast_host" json:"domainName"`     // 主域名
+	RecordName   string     `gorm:"not null;uniqueIndex:idx_fast_host" json:"recordName"` // 主机记录，主域名本身为 @
+	RecordInfo   RecordInfo `gorm:"serializer:json" json:"recordInfo"`                    // A 记录
+	RecordInfoV6 RecordInfo `gorm:"serializer:json" json:"recordInfoV6"`                  // AAAA 记录
+	CreateTime   time.Time  `gorm:"null" json:"createTime"`
+	UpdateTime   time.Time  `gorm:"null" json:"updateTime"`
+}
+
+// BeforeSave 保存前更新修改时间
+func (r *FastRecord) BeforeSave(*gorm.DB) error {
+	r.UpdateTime = time.Now()
+	return nil
+}
+
+// Zone 获取记录所在的域名
+func (r *FastRecord) Zone() FastZone {
+	return FastZone{UseAccount: r.AccountName, DomainId: r.DomainId, DomainName: r.DomainName}
+}
+
+// Label 获取主机记录
+func (r *FastRecord) Label() string {
+	return r.RecordName
+}
+
+// FQDN 获取完整域名
+func (r *FastRecord) FQDN() string {
+	return fastFQDN(r.RecordName, r.DomainName)
+}
+
+// Record 获取指定类型的记录，A 以外的类型都视为 AAAA
+func (r *FastRecord) Record(recordType string) *RecordInfo {
+	if recordType == "A" {
+		return &r.RecordInfo
+	}
+	return &r.RecordInfoV6
+}
+
+// FastHistory 快速解析记录的变更历史
+type FastHistory struct {
+	Id          int       `gorm:"primaryKey" json:"id"`
+	FastId      int       `gorm:"not null;index" json:"fastId"` // 快速解析记录ID
+	TokenPrefix string    `gorm:"null" json:"tokenPrefix"`      // Token 前缀
+	FQDN        string    `gorm:"null;index" json:"fqdn"`       // 完整域名
+	RecordType  string    `gorm:"null" json:"recordType"`       // 记录类型
+	OldIP       string    `gorm:"null" json:"oldIp"`            // 变更前的地址，新建记录时为空
+	NewIP       string    `gorm:"null" json:"newIp"`            // 变更后的地址
+	SourceIP    string    `gorm:"null" json:"sourceIp"`         // 请求来源 IP
+	UserAgent   string    `gorm:"null" json:"userAgent"`        // 请求的 User-Agent
+	CreateTime  time.Time `gorm:"null;index" json:"createTime"` // 变更时间
+}
+
+// FastSequence 快速解析编号，每个解析池一条，LastId 为下一个待分配的编号
+type FastSequence struct {
+	Id     int    `gorm:"primaryKey"`
+	Pool   string `gorm:"not null;default:'';uniqueIndex"`
+	LastId int    `gorm:"not null"`
+}
+
+// FastDataJson 旧版 fastData.json 的格式，仅用于导入数据库
+type FastDataJson struct {
+	DataList []FastData `json:"dataList"`
+	LastId   int        `json:"lastId"`
+}
+
+func (f *FastDataJson) LoadFromJson(filePath string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
 	}
-	var fastDataJson FastDataJson
-	err := fastDataJson.LoadFromJson(filePath)
+
+	err = json.Unmarshal(data, f)
 	if err != nil {
-		return fastDataJson, err
+		return err
 	}
 
-	return fastDataJson, nil
+	// 旧版数据没有单独保存主机记录
+	for i, fastData := range f.DataList {
+		if fastData.RecordName == "" {
+			f.DataList[i].RecordName = fastData.RecordInfo.RecordName
+		}
+		if f.DataList[i].RecordName == "" {
+			f.DataList[i].RecordName = fastData.RecordInfoV6.RecordName
+		}
+	}
+	return nil
 }
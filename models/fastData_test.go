@@ -0,0 +1,68 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFastDataExpired(t *testing.T) {
+	now := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		data FastData
+		want bool
+	}{
+		"不限制":       {FastData{CreateTime: now.AddDate(-1, 0, 0)}, false},
+		"已过期":       {FastData{ExpireTime: now.Add(-time.Hour)}, true},
+		"未过期":       {FastData{ExpireTime: now.Add(time.Hour)}, false},
+		"长时间未更新":    {FastData{InactiveDays: 7, LastSeenTime: now.AddDate(0, 0, -8)}, true},
+		"近期更新":      {FastData{InactiveDays: 7, LastSeenTime: now.AddDate(0, 0, -6)}, false},
+		"从未更新按创建时间": {FastData{InactiveDays: 7, CreateTime: now.AddDate(0, 0, -8)}, true},
+	} {
+		if got := tc.data.Expired(now); got != tc.want {
+			t.Errorf("%s: Expired() = %v, want %v", name, got, tc.want)
+		}
+	}
+}
+
+func TestFastConfigZone(t *testing.T) {
+	config := FastConfig{
+		UseAccount: "account1",
+		DomainName: "a.com",
+		Zones:      []FastZone{{DomainName: "b.com"}, {UseAccount: "account2", DomainName: "c.com"}},
+	}
+	for domainName, want := range map[string]string{"": "account1", "A.com": "account1", "b.com": "account1", "c.com": "account2"} {
+		zone, ok := config.GetZone(domainName)
+		if !ok || zone.UseAccount != want {
+			t.Errorf("GetZone(%q) = %+v, %v", domainName, zone, ok)
+		}
+	}
+	if _, ok := config.GetZone("d.com"); ok {
+		t.Error("未配置的域名不允许使用")
+	}
+	for label, want := range map[string]bool{"box1": true, "my-box": true, "-box": false, "a.b": false, "": false} {
+		if config.ValidLabel(label) != want {
+			t.Errorf("ValidLabel(%q) = %v, want %v", label, !want, want)
+		}
+	}
+	data := FastData{DomainName: "a.com", RecordName: "box1"}
+	if data.FQDN() != "box1.a.com" {
+		t.Errorf("FQDN() = %q", data.FQDN())
+	}
+	data.RecordName = "box1.a.com"
+	if data.FQDN() != "box1.a.com" {
+		t.Errorf("FQDN() = %q", data.FQDN())
+	}
+}
+
+func TestFastConfigPool(t *testing.T) {
+	config := FastConfig{NameStrata: "a_", Pools: []FastConfig{{Name: "lab", NameStrata: "lab_"}}}
+	if pool, ok := config.GetPool(""); !ok || pool.NameStrata != "a_" || pool.Pools != nil {
+		t.Errorf("GetPool(\"\") = %+v, %v", pool, ok)
+	}
+	if pool, ok := config.GetPool("lab"); !ok || pool.NameStrata != "lab_" {
+		t.Errorf("GetPool(lab) = %+v, %v", pool, ok)
+	}
+	if _, ok := config.GetPool("other"); ok {
+		t.Error("不存在的解析池应返回 false")
+	}
+}
@@ -1,8 +1,24 @@
 package requestModel
 
+import (
+	"encoding/json"
+	"time"
+)
+
+// AcmeRequest 证书申请的 CA 参数，为空时使用配置文件中的默认值
+type AcmeRequest struct {
+	CA         string `form:"ca" json:"ca"`                 // CA 名称或目录地址
+	KeyType    string `form:"keyType" json:"keyType"`       // 密钥类型 RSA2048 | RSA4096 | EC256 | EC384
+	EABKeyId   string `form:"eabKeyId" json:"eabKeyId"`     // External Account Binding Key ID
+	EABHmacKey string `form:"eabHmacKey" json:"eabHmacKey"` // External Account Binding HMAC Key
+}
+
 type CreateCertificateRequest struct {
+	AcmeRequest
 	DomainId     string `form:"domainId" json:"domainId" uri:"domainId"`
 	DomainIdList string `form:"domainIdList" json:"domainIdList" uri:"domainIdList"`
+	SanList      string `form:"sanList" json:"sanList"` // 证书包含的域名，逗号分隔，为空时申请通配符与主域名
+	Queue        string `form:"queue" json:"queue"`     // 任务队列 critical | default | low
 }
 
 type GetCertificateListRequest struct {
@@ -11,7 +27,10 @@ type GetCertificateListRequest struct {
 }
 
 type DomainNameListRequest struct {
+	AcmeRequest
 	DomainNameList string `form:"domainNameList" json:"domainNameList" uri:"domainNameList" binding:"required"`
+	SanList        string `form:"sanList" json:"sanList"` // 证书包含的域名，逗号分隔，为空时申请通配符与主域名
+	Queue          string `form:"queue" json:"queue"`     // 任务队列 critical | default | low
 }
 
 type CertificateIdRequest struct {
@@ -21,8 +40,185 @@ type CertificateIdRequest struct {
 type DownloadCertificateViewWithIdRequest struct {
 	CertificateIdRequest
 	DownloadType string `form:"downloadType" json:"downloadType" uri:"downloadType" binding:"required"`
+	Password     string `form:"password" json:"password"` // pfx / jks 密码
+}
+
+type RevokeCertificateRequest struct {
+	CertificateIdRequest
+	Reason      uint `form:"reason" json:"reason"`           // 吊销原因，RFC 5280 CRLReason
+	DeleteFiles bool `form:"deleteFiles" json:"deleteFiles"` // 是否同时删除证书文件
+}
+
+type DeleteCertificateRequest struct {
+	CertificateIdRequest
+	DeleteFiles bool `form:"deleteFiles" json:"deleteFiles"` // 是否同时删除证书文件
+}
+
+type ChallengeClientRequest struct {
+	Name      string `form:"name" json:"name"`
+	Domains   string `form:"domains" json:"domains" binding:"required"` // 允许的域名列表，逗号分隔
+	AllowFrom string `form:"allowFrom" json:"allowFrom"`                // 允许的来源 IP 段，逗号分隔
+}
+
+// AcmeDnsUpdateRequest acme-dns /update 请求
+type AcmeDnsUpdateRequest struct {
+	Subdomain string `json:"subdomain" binding:"required"`
+	Txt       string `json:"txt" binding:"required"`
+}
+
+// HttpReqRequest lego httpreq 请求，默认模式使用 fqdn 与 value，RAW 模式使用 domain、token 与 keyAuth
+type HttpReqRequest struct {
+	FQDN    string `json:"fqdn"`
+	Value   string `json:"value"`
+	Domain  string `json:"domain"`
+	Token   string `json:"token"`
+	KeyAuth string `json:"keyAuth"`
+}
+
+type DDNSClientRequest struct {
+	Name      string `form:"name" json:"name"`
+	Username  string `form:"username" json:"username"`                      // 用户名，为空时随机生成
+	Hostnames string `form:"hostnames" json:"hostnames" binding:"required"` // 允许更新的完整域名，逗号分隔
+}
+
+// NicUpdateRequest DynDNS2 /nic/update 请求
+type NicUpdateRequest struct {
+	Hostname string `form:"hostname"` // 更新的完整域名，多个使用逗号分隔
+	MyIP     string `form:"myip"`     // IPv4 地址（也可以是逗号分隔的 IPv4 / IPv6 地址），为空时使用请求来源 IP
+	MyIPv6   string `form:"myipv6"`   // IPv6 地址
+}
+
+// FastAddressRequest 快速解析地址参数，由已鉴权的客户端上报自身地址，均为空时使用请求来源 IP
+type FastAddressRequest struct {
+	IP   string `form:"ip" json:"ip"` // 逗号分隔的 IPv4 / IPv6 地址
+	IPv4 string `form:"ipv4" json:"ipv4"`
+	IPv6 string `form:"ipv6" json:"ipv6"`
+}
+
+// FastCreateRequest 创建快速解析记录
+type FastCreateRequest struct {
+	Label string `form:"label" json:"label"` // 自定义主机记录，为空时按编号分配
+	Zone  string `form:"zone" json:"zone"`   // 使用的域名，为空时使用默认域名
+}
+
+type FastTokenListRequest struct {
+	KeyWord  string `form:"keyWord" json:"keyWord"` // 匹配主机记录、备注名称与 IP
+	Page     int    `form:"page" json:"page"`
+	PageSize int    `form:"pageSize" json:"pageSize"`
+}
+
+// FastRecordRequest 为快速解析 Token 添加其他主机记录，Id 为 Token 的记录ID
+type FastRecordRequest struct {
+	IdRequest
+	Hostname string `form:"hostname" json:"hostname" binding:"required"` // 完整域名，支持通配符，如 *.box1.a.com
 }
 
-type TaskIdRequest struct {
+type FastHistoryListRequest struct {
+	FastId   int    `form:"fastId" json:"fastId"`   // 快速解析记录ID，为空时查询全部
+	KeyWord  string `form:"keyWord" json:"keyWord"` // 匹配域名与 IP
+	Page     int    `form:"page" json:"page"`
+	PageSize int    `form:"pageSize" json:"pageSize"`
+}
+
+type FastTokenUpdateRequest struct {
+	IdRequest
+	Name         string    `form:"name" json:"name"`                 // 备注名称
+	ExpireTime   time.Time `form:"expireTime" json:"expireTime"`     // 过期时间（RFC 3339），为空时不过期
+	InactiveDays int       `form:"inactiveDays" json:"inactiveDays"` // 超过多少天未更新视为失效，为 0 时不限制
+}
+
+type IdRequest struct {
 	Id int `form:"id" json:"id" uri:"id" binding:"required"`
 }
+
+type CertificateDeployRequest struct {
+	CertificateId int    `form:"certificateId" json:"certificateId" binding:"required"`
+	Type          string `form:"type" json:"type" binding:"required"` // 部署类型 copy | command | webhook
+	CertPath      string `form:"certPath" json:"certPath"`
+	KeyPath       string `form:"keyPath" json:"keyPath"`
+	Owner         string `form:"owner" json:"owner"`
+	FileMode      string `form:"fileMode" json:"fileMode"`
+	Command       string `form:"command" json:"command"`
+	WebhookUrl    string `form:"webhookUrl" json:"webhookUrl"`
+	WithKey       bool   `form:"withKey" json:"withKey"`
+}
+
+type PullTokenRequest struct {
+	Name          string `form:"name" json:"name"`
+	CertificateId int    `form:"certificateId" json:"certificateId"` // 绑定的证书
+	Domain        string `form:"domain" json:"domain"`               // 允许拉取的域名（包含子域名）
+}
+
+type PullCertificateRequest struct {
+	Hostname     string `form:"hostname" json:"hostname" binding:"required"`
+	DownloadType string `form:"type" json:"type"`
+	Password     string `form:"password" json:"password"` // pfx / jks 密码
+}
+
+// CertManagerChallengeRequest cert-manager webhook 的 ChallengeRequest
+type CertManagerChallengeRequest struct {
+	UID                     string          `json:"uid"`
+	Action                  string          `json:"action"` // Present | CleanUp
+	Type                    string          `json:"type"`   // dns-01
+	DNSName                 string          `json:"dnsName"`
+	Key                     string          `json:"key"`
+	ResourceNamespace       string          `json:"resourceNamespace"`
+	ResolvedFQDN            string          `json:"resolvedFQDN"`
+	ResolvedZone            string          `json:"resolvedZone"`
+	AllowAmbientCredentials bool            `json:"allowAmbientCredentials"`
+	Config                  json.RawMessage `json:"config,omitempty"`
+}
+
+// CertManagerChallengeStatus cert-manager webhook 的失败原因
+type CertManagerChallengeStatus struct {
+	Status  string `json:"status,omitempty"`
+	Message string `json:"message,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Code    int    `json:"code,omitempty"`
+}
+
+// CertManagerChallengeResponse cert-manager webhook 的 ChallengeResponse
+type CertManagerChallengeResponse struct {
+	UID     string                      `json:"uid"`
+	Success bool                        `json:"success"`
+	Status  *CertManagerChallengeStatus `json:"status,omitempty"`
+}
+
+// CertManagerChallengePayload cert-manager webhook 的 ChallengePayload
+type CertManagerChallengePayload struct {
+	APIVersion string                        `json:"apiVersion"`
+	Kind       string                        `json:"kind"`
+	Request    *CertManagerChallengeRequest  `json:"request,omitempty"`
+	Response   *CertManagerChallengeResponse `json:"response,omitempty"`
+}
+
+// ExternalDNSEndpoint ExternalDNS webhook 的 Endpoint
+type ExternalDNSEndpoint struct {
+	DNSName          string                            `json:"dnsName,omitempty"`
+	Targets          []string                          `json:"targets,omitempty"`
+	RecordType       string                            `json:"recordType,omitempty"`
+	SetIdentifier    string                            `json:"setIdentifier,omitempty"`
+	RecordTTL        int64                             `json:"recordTTL,omitempty"`
+	Labels           map[string]string                 `json:"labels,omitempty"`
+	ProviderSpecific []ExternalDNSProviderSpecificProp `json:"providerSpecific,omitempty"`
+}
+
+// ExternalDNSProviderSpecificProp ExternalDNS webhook 的 ProviderSpecificProperty
+type ExternalDNSProviderSpecificProp struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ExternalDNSChanges ExternalDNS webhook 的 Changes，字段名与 ExternalDNS 保持一致
+type ExternalDNSChanges struct {
+	Create    []*ExternalDNSEndpoint `json:"Create"`
+	UpdateOld []*ExternalDNSEndpoint `json:"UpdateOld"`
+	UpdateNew []*ExternalDNSEndpoint `json:"UpdateNew"`
+	Delete    []*ExternalDNSEndpoint `json:"Delete"`
+}
+
+// ExternalDNSDomainFilter ExternalDNS webhook 协商时返回的域名过滤
+type ExternalDNSDomainFilter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
@@ -19,6 +19,7 @@ const (
 	BadRequestCode   = 400
 	NotFoundCode     = 404
 	UnauthorizedCode = 401
+	ConflictCode     = 409
 )
 
 // Success 生成成功响应
@@ -58,6 +59,11 @@ func Unauthorized(c *gin.Context, message string) {
 	Error(c, UnauthorizedCode, message, nil)
 }
 
+// Conflict 生成409响应
+func Conflict(c *gin.Context, message string) {
+	Error(c, ConflictCode, message, nil)
+}
+
 // Forbidden 生成403响应
 func Forbidden(c *gin.Context, message string) {
 	Error(c, 403, message, nil)
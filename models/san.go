@@ -0,0 +1,81 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var sanPattern = regexp.MustCompile(`^(\*\.)?([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ChallengeDomain 获取 SAN 对应的 DNS-01 验证域名（去掉通配符前缀）
+func ChallengeDomain(name string) string {
+	return strings.TrimPrefix(name, "*.")
+}
+
+// ValidateSan 校验 SAN 格式，通配符只允许出现在最左侧
+func ValidateSan(name string) error {
+	if len(name) > 253 || !sanPattern.MatchString(name) {
+		return fmt.Errorf("域名格式错误: %s", name)
+	}
+	return nil
+}
+
+// ParseSanList 解析逗号分隔的 SAN 列表，统一转为小写并去重
+func ParseSanList(sanList string) ([]string, error) {
+	var result []string
+	exist := map[string]bool{}
+	for _, name := range strings.Split(sanList, ",") {
+		name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+		if name == "" || exist[name] {
+			continue
+		}
+		if err := ValidateSan(name); err != nil {
+			return nil, err
+		}
+		exist[name] = true
+		result = append(result, name)
+	}
+	return result, nil
+}
+
+// DefaultSanList 未指定 SAN 时，为每个域名申请通配符与主域名
+func DefaultSanList(domains []DomainInfo) []string {
+	var sanList []string
+	for _, domain := range domains {
+		sanList = append(sanList, "*."+domain.DomainName, domain.DomainName)
+	}
+	return sanList
+}
+
+// FindZone 查找域名所属的主域名，存在多个匹配时取最长的一个
+func FindZone(name string, zones []DomainInfo) (DomainInfo, bool) {
+	name = strings.ToLower(strings.TrimSuffix(ChallengeDomain(name), "."))
+	var result DomainInfo
+	found := false
+	for _, zone := range zones {
+		zoneName := strings.ToLower(zone.DomainName)
+		if name != zoneName && !strings.HasSuffix(name, "."+zoneName) {
+			continue
+		}
+		if !found || len(zoneName) > len(result.DomainName) {
+			result = zone
+			found = true
+		}
+	}
+	return result, found
+}
+
+// GetChallengeDomainList 获取 SAN 列表需要进行验证的域名（去重）
+func GetChallengeDomainList(sanList []string) []string {
+	var result []string
+	exist := map[string]bool{}
+	for _, name := range sanList {
+		domain := ChallengeDomain(name)
+		if !exist[domain] {
+			exist[domain] = true
+			result = append(result, domain)
+		}
+	}
+	return result
+}
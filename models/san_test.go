@@ -0,0 +1,60 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSanList(t *testing.T) {
+	sanList, err := ParseSanList(" API.a.com,www.b.com.,*.dev.a.com,api.a.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"api.a.com", "www.b.com", "*.dev.a.com"}
+	if !reflect.DeepEqual(sanList, want) {
+		t.Errorf("ParseSanList = %v, want %v", sanList, want)
+	}
+
+	for _, name := range []string{"a.*.com", "*", "-a.com", "a..com", "**.a.com"} {
+		if _, err := ParseSanList(name); err == nil {
+			t.Errorf("ParseSanList(%q) 应返回错误", name)
+		}
+	}
+}
+
+func TestFindZone(t *testing.T) {
+	zones := []DomainInfo{
+		{Domains: Domains{Id: "1", DomainName: "a.com"}},
+		{Domains: Domains{Id: "2", DomainName: "dev.a.com"}},
+		{Domains: Domains{Id: "3", DomainName: "b.com"}},
+	}
+	tests := map[string]string{
+		"a.com":           "1",
+		"api.a.com":       "1",
+		"*.a.com":         "1",
+		"*.dev.a.com":     "2",
+		"x.y.dev.a.com":   "2",
+		"www.b.com":       "3",
+		"notb.com":        "",
+		"c.com":           "",
+		"a.com.evil.com":  "",
+		"deva.com":        "",
+		"api.dev.a.com.":  "2",
+		"WWW.B.COM":       "3",
+		"*.api.dev.a.com": "2",
+	}
+	for name, wantId := range tests {
+		zone, ok := FindZone(name, zones)
+		if ok != (wantId != "") || zone.Id != wantId {
+			t.Errorf("FindZone(%q) = %q, %v, want %q", name, zone.Id, ok, wantId)
+		}
+	}
+}
+
+func TestGetChallengeDomainList(t *testing.T) {
+	got := GetChallengeDomainList([]string{"*.a.com", "a.com", "*.dev.a.com", "api.a.com"})
+	want := []string{"a.com", "dev.a.com", "api.a.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetChallengeDomainList = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,188 @@
+package notify
+
+import (
+	"DDNSServer/models"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// telegramApi Telegram 机器人接口地址
+const telegramApi = "https://api.telegram.org"
+
+// sendChannel 按渠道类型发送通知
+func sendChannel(ctx context.Context, channel models.NotifyChannel, event Event) error {
+	switch channel.Type {
+	case "webhook":
+		return postJSON(ctx, channel.Url, event)
+	case "telegram":
+		apiUrl := channel.Url
+		if apiUrl == "" {
+			apiUrl = telegramApi
+		}
+		return postJSON(ctx, strings.TrimSuffix(apiUrl, "/")+"/bot"+channel.BotToken+"/sendMessage", map[string]string{
+			"chat_id": channel.ChatId,
+			"text":    event.Text(),
+		})
+	case "dingtalk":
+		webhookUrl := channel.Url
+		if channel.Secret != "" {
+			timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			sign := hmacSign(channel.Secret, timestamp+"\n"+channel.Secret)
+			webhookUrl += "&timestamp=" + timestamp + "&sign=" + url.QueryEscape(sign)
+		}
+		return postJSON(ctx, webhookUrl, map[string]any{
+			"msgtype": "text",
+			"text":    map[string]string{"content": event.Text()},
+		})
+	case "wecom":
+		return postJSON(ctx, channel.Url, map[string]any{
+			"msgtype": "text",
+			"text":    map[string]string{"content": event.Text()},
+		})
+	case "feishu":
+		body := map[string]any{
+			"msg_type": "text",
+			"content":  map[string]string{"text": event.Text()},
+		}
+		if channel.Secret != "" {
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			body["timestamp"] = timestamp
+			body["sign"] = hmacSign(timestamp+"\n"+channel.Secret, "")
+		}
+		return postJSON(ctx, channel.Url, body)
+	case "smtp":
+		return sendMail(channel, event)
+	default:
+		return fmt.Errorf("未知的通知类型: %s", channel.Type)
+	}
+}
+
+// hmacSign 计算 HMAC-SHA256 签名并使用 base64 编码
+func hmacSign(key, data string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(data))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// postJSON 发送 JSON 请求，机器人接口在响应体中返回错误码
+func postJSON(ctx context.Context, webhookUrl string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookUrl, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(response.Body, 2000))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("返回状态码 %d: %s", response.StatusCode, respBody)
+	}
+	// 钉钉、企业微信返回 errcode，飞书返回 code，通用 webhook 的响应体不做要求
+	var result struct {
+		ErrCode int `json:"errcode"`
+		Code    int `json:"code"`
+	}
+	if json.Unmarshal(respBody, &result) == nil && (result.ErrCode != 0 || result.Code != 0) {
+		return fmt.Errorf("返回错误: %s", respBody)
+	}
+	return nil
+}
+
+// sendMail 发送邮件通知，465 端口使用 TLS 连接，其他端口由服务器决定是否使用 STARTTLS
+func sendMail(channel models.NotifyChannel, event Event) error {
+	if channel.Host == "" || len(channel.To) == 0 {
+		return errors.New("SMTP 服务器与收件人不能为空")
+	}
+	from := channel.From
+	if from == "" {
+		from = channel.Username
+	}
+	message := strings.Join([]string{
+		"From: " + from,
+		"To: " + strings.Join(channel.To, ", "),
+		"Subject: " + mime.BEncoding.Encode("UTF-8", event.Title()),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		event.Text(),
+	}, "\r\n")
+	addr := net.JoinHostPort(channel.Host, strconv.Itoa(channel.Port))
+	var auth smtp.Auth
+	if channel.Username != "" {
+		auth = smtp.PlainAuth("", channel.Username, channel.Password, channel.Host)
+	}
+	dialer := &net.Dialer{Timeout: sendTimeout}
+	var conn net.Conn
+	var err error
+	if channel.Port == 465 {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: channel.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	// 整个会话使用同一个超时时间，避免服务器无响应时一直阻塞
+	if err = conn.SetDeadline(time.Now().Add(sendTimeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	client, err := smtp.NewClient(conn, channel.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok && channel.Port != 465 {
+		if err = client.StartTLS(&tls.Config{ServerName: channel.Host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if err = client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err = client.Mail(from); err != nil {
+		return err
+	}
+	for _, to := range channel.To {
+		if err = client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write([]byte(message)); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,109 @@
+package notify
+
+import (
+	"DDNSServer/models"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+const (
+	EventChange   = "change"   // 地址变更
+	EventInactive = "inactive" // 长时间未上报
+)
+
+// sendTimeout 单个通知渠道的发送超时时间
+const sendTimeout = 10 * time.Second
+
+// Event 通知事件，同时作为通用 webhook 的请求体
+type Event struct {
+	Type         string    `json:"type"`
+	FastId       int       `json:"fastId"`
+	Name         string    `json:"name,omitempty"`
+	FQDN         string    `json:"fqdn"`
+	RecordType   string    `json:"recordType,omitempty"`
+	OldIP        string    `json:"oldIp,omitempty"`
+	NewIP        string    `json:"newIp,omitempty"`
+	SourceIP     string    `json:"sourceIp,omitempty"`
+	UserAgent    string    `json:"userAgent,omitempty"`
+	LastSeenTime time.Time `json:"lastSeenTime"`
+	Time         time.Time `json:"time"`
+}
+
+// ChangeEvent 快速解析地址变更事件
+func ChangeEvent(fastData models.FastData, history models.FastHistory) Event {
+	return Event{
+		Type:         EventChange,
+		FastId:       fastData.Id,
+		Name:         fastData.Name,
+		FQDN:         history.FQDN,
+		RecordType:   history.RecordType,
+		OldIP:        history.OldIP,
+		NewIP:        history.NewIP,
+		SourceIP:     history.SourceIP,
+		UserAgent:    history.UserAgent,
+		LastSeenTime: fastData.LastSeenTime,
+		Time:         history.CreateTime,
+	}
+}
+
+// InactiveEvent 快速解析长时间未上报事件
+func InactiveEvent(fastData models.FastData, now time.Time) Event {
+	return Event{
+		Type:         EventInactive,
+		FastId:       fastData.Id,
+		Name:         fastData.Name,
+		FQDN:         fastData.FQDN(),
+		LastSeenTime: fastData.LastActiveTime(),
+		Time:         now,
+	}
+}
+
+// Title 通知标题
+func (e Event) Title() string {
+	if e.Type == EventInactive {
+		return "DomainSprite 主机未上报：" + e.FQDN
+	}
+	return "DomainSprite 地址变更：" + e.FQDN
+}
+
+// Text 通知正文
+func (e Event) Text() string {
+	const layout = "2006-01-02 15:04:05"
+	name := e.FQDN
+	if e.Name != "" {
+		name = fmt.Sprintf("%s（%s）", e.FQDN, e.Name)
+	}
+	if e.Type == EventInactive {
+		return fmt.Sprintf("%s\n主机 %s 自 %s 起未上报地址", e.Title(), name, e.LastSeenTime.Format(layout))
+	}
+	oldIP := e.OldIP
+	if oldIP == "" {
+		oldIP = "无"
+	}
+	return fmt.Sprintf("%s\n主机 %s 的 %s 记录已变更\n%s -> %s\n来源 %s\n时间 %s",
+		e.Title(), name, e.RecordType, oldIP, e.NewIP, e.SourceIP, e.Time.Format(layout))
+}
+
+// Enabled 判断是否配置了通知渠道
+func Enabled() bool {
+	return len(models.AccountConfig.Notify.Channels) > 0
+}
+
+// Send 向所有订阅了该事件的渠道异步发送通知，发送失败只记录日志
+func Send(event Event) {
+	for _, channel := range models.AccountConfig.Notify.Channels {
+		if len(channel.Events) > 0 && !slices.Contains(channel.Events, event.Type) {
+			continue
+		}
+		go func(channel models.NotifyChannel) {
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			defer cancel()
+			if err := sendChannel(ctx, channel, event); err != nil {
+				slog.Error("发送通知失败", "type", channel.Type, "event", event.Type, "fqdn", event.FQDN, "err", err)
+			}
+		}(channel)
+	}
+}
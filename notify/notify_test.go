@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"DDNSServer/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestSendChannel(t *testing.T) {
+	var body map[string]any
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = map[string]any{}
+		query = r.URL.RawQuery
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["msgtype"] == "error" {
+			w.Write([]byte(`{"errcode": 310000, "errmsg": "sign not match"}`))
+			return
+		}
+		w.Write([]byte(`{"errcode": 0, "errmsg": "ok"}`))
+	}))
+	defer server.Close()
+	event := Event{Type: EventChange, FQDN: "ddns001.a.com", RecordType: "A", OldIP: "192.0.2.1", NewIP: "192.0.2.2"}
+
+	if err := sendChannel(context.Background(), models.NotifyChannel{Type: "webhook", Url: server.URL}, event); err != nil {
+		t.Fatal(err)
+	}
+	if body["fqdn"] != "ddns001.a.com" || body["newIp"] != "192.0.2.2" {
+		t.Errorf("webhook body = %v", body)
+	}
+
+	channel := models.NotifyChannel{Type: "dingtalk", Url: server.URL + "/robot/send?access_token=x", Secret: "s"}
+	if err := sendChannel(context.Background(), channel, event); err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := body["text"].(map[string]any); text["content"] != event.Text() {
+		t.Errorf("dingtalk body = %v", body)
+	}
+	if values, _ := url.ParseQuery(query); values.Get("sign") == "" || values.Get("timestamp") == "" {
+		t.Errorf("dingtalk query = %s", query)
+	}
+
+	if err := postJSON(context.Background(), server.URL, map[string]string{"msgtype": "error"}); err == nil {
+		t.Error("机器人返回错误码时应返回错误")
+	}
+	if err := sendChannel(context.Background(), models.NotifyChannel{Type: "unknown"}, event); err == nil {
+		t.Error("未知的通知类型应返回错误")
+	}
+}
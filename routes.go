@@ -40,17 +40,126 @@ func registerRoutes(r *gin.Engine) {
 		certificate.GET("/info", views.GetCertificateViewWithId)
 		// 下载证书
 		certificate.GET("/download", views.DownloadCertificateViewWithId)
+		// 手动续期证书
+		certificate.POST("/renew", views.RenewCertificateView)
+		// 吊销证书
+		certificate.POST("/revoke", views.RevokeCertificateView)
+		// 删除证书
+		certificate.DELETE("", views.DeleteCertificateView)
+		// 获取证书部署目标列表
+		certificate.GET("/deploy", views.GetCertificateDeployListView)
+		// 添加证书部署目标
+		certificate.POST("/deploy", views.AddCertificateDeployView)
+		// 删除证书部署目标
+		certificate.DELETE("/deploy", views.DeleteCertificateDeployView)
+		// 立即执行部署
+		certificate.POST("/deploy/run", views.RunCertificateDeployView)
+		// 获取证书拉取 Token 列表
+		certificate.GET("/token", views.GetPullTokenListView)
+		// 创建证书拉取 Token
+		certificate.POST("/token", views.CreatePullTokenView)
+		// 吊销证书拉取 Token
+		certificate.DELETE("/token", views.RevokePullTokenView)
 		// 获取证书任务列表
 		certificate.GET("/task", views.GetCertificateTaskInfoByCertificateId)
 		// 获取证书任务日志
 		certificate.GET("/task/log", views.GetTaskLog)
 	}
+	// 证书拉取（Token 鉴权）
+	pull := r.Group("/pull", views.PullAuthentication)
+	{
+		// 拉取覆盖指定域名的最新证书
+		pull.GET("/certificate", views.PullCertificateView)
+	}
+	// 外部 ACME 客户端管理
+	challenge := r.Group("/challenge", views.ApiAuthentication)
+	{
+		// 获取客户端列表
+		challenge.GET("/client", views.GetChallengeClientListView)
+		// 删除客户端
+		challenge.DELETE("/client", views.DeleteChallengeClientView)
+	}
+	// acme-dns 协议
+	acmeDns := r.Group("/acme-dns")
+	{
+		// 注册客户端（管理密钥鉴权）
+		acmeDns.POST("/register", views.ApiAuthentication, views.RegisterAcmeDnsView)
+		// 更新 TXT 记录
+		acmeDns.POST("/update", views.ChallengeClientAuthentication, views.AcmeDnsUpdateView)
+		// 健康检查
+		acmeDns.GET("/health", views.AcmeDnsHealthView)
+	}
+	// lego httpreq 协议
+	httpReq := r.Group("/httpreq", views.ChallengeClientAuthentication)
+	{
+		// 添加验证记录
+		httpReq.POST("/present", views.HttpReqPresentView)
+		// 删除验证记录
+		httpReq.POST("/cleanup", views.HttpReqCleanUpView)
+	}
+	// cert-manager webhook
+	certManager := r.Group("/cert-manager", views.ChallengeClientAuthentication)
+	{
+		// 处理 Present / CleanUp 请求
+		certManager.POST("/challenge", views.CertManagerChallengeView)
+	}
+	// DynDNS2 专用凭据管理
+	ddns := r.Group("/ddns", views.ApiAuthentication)
+	{
+		// 获取凭据列表
+		ddns.GET("/client", views.GetDDNSClientListView)
+		// 创建凭据
+		ddns.POST("/client", views.CreateDDNSClientView)
+		// 删除凭据
+		ddns.DELETE("/client", views.DeleteDDNSClientView)
+	}
+	// DynDNS2 协议，供路由器、NAS 等设备使用
+	nic := r.Group("/nic", views.DynDNSAuthentication)
+	{
+		// 更新域名地址
+		nic.GET("/update", views.NicUpdateView)
+		nic.POST("/update", views.NicUpdateView)
+	}
+	// 公网 IP 查询，无需鉴权
+	ipEcho := r.Group("", views.IpEchoRateLimit)
+	{
+		// 以纯文本返回请求来源 IP
+		ipEcho.GET("/ip", views.IpEchoView)
+		// 以 JSON 返回请求来源 IP
+		ipEcho.GET("/ip.json", views.IpEchoJsonView)
+		// 只返回 IPv4 地址
+		ipEcho.GET("/ipv4", views.Ipv4EchoView)
+		// 只返回 IPv6 地址
+		ipEcho.GET("/ipv6", views.Ipv6EchoView)
+	}
 	// 快速请求
-	fastRequest := r.Group("/fast")
+	fastRequest := r.Group("/fast", views.FastPoolSelect)
 	{
 		// 创建一个A解析并返回快速解析token
 		fastRequest.GET("/ip2a", views.FastAuthentication, views.IpToDomainRecord)
 		// 对指定的解析进行更新
 		fastRequest.GET("/updateRecord", views.UpdateForToken)
+		// 在指定的解析池中创建解析
+		fastRequest.GET("/:pool/ip2a", views.FastAuthentication, views.IpToDomainRecord)
+		// 更新指定解析池中的解析
+		fastRequest.GET("/:pool/updateRecord", views.UpdateForToken)
+	}
+	// 快速解析 Token 管理
+	fastToken := r.Group("/fast/token", views.ApiAuthentication)
+	{
+		// 获取 Token 列表
+		fastToken.GET("", views.GetFastTokenListView)
+		// 修改备注名称与生命周期
+		fastToken.PUT("", views.UpdateFastTokenView)
+		// 重新生成 Token
+		fastToken.POST("/rotate", views.RotateFastTokenView)
+		// 吊销 Token 并删除解析记录
+		fastToken.DELETE("", views.RevokeFastTokenView)
+		// 获取解析变更历史
+		fastToken.GET("/history", views.GetFastHistoryView)
+		// 为 Token 添加其他主机记录
+		fastToken.POST("/record", views.AddFastRecordView)
+		// 删除 Token 的其他主机记录
+		fastToken.DELETE("/record", views.DeleteFastRecordView)
 	}
 }
@@ -0,0 +1,58 @@
+package testutil
+
+import (
+	"DDNSServer/models"
+	"strconv"
+)
+
+// FakeProvider 内存中的 RecordProvider，主机记录使用 @ 与相对名称，供各模块的测试使用
+type FakeProvider struct {
+	Records []models.RecordInfo
+	NextId  int // 最后分配的记录ID，新增记录时先加一
+}
+
+func (f *FakeProvider) GetAccountInfo() models.Account { return models.Account{Name: "fake"} }
+func (f *FakeProvider) GetDomainList(models.DomainsSearch) (models.DomainList, error) {
+	return models.DomainList{}, nil
+}
+func (f *FakeProvider) GetRecordList(search models.DNSSearch) (models.RecordInfoList, error) {
+	var result models.RecordInfoList
+	for _, record := range f.Records {
+		if record.DomainName == search.DomainName &&
+			(search.RRKeyWord == "" || record.RecordName == search.RRKeyWord) &&
+			(search.TypeKeyWord == "" || record.RecordType == search.TypeKeyWord) {
+			result.Records = append(result.Records, record)
+		}
+	}
+	result.TotalCount = int64(len(result.Records))
+	return result, nil
+}
+func (f *FakeProvider) AddRecord(info models.RecordInfo) (models.RecordInfo, error) {
+	f.NextId++
+	info.Id = strconv.Itoa(f.NextId)
+	f.Records = append(f.Records, info)
+	return info, nil
+}
+func (f *FakeProvider) UpdateRecord(info models.RecordInfo) (models.RecordInfo, error) {
+	for i, record := range f.Records {
+		if record.Id == info.Id {
+			f.Records[i] = info
+		}
+	}
+	return info, nil
+}
+func (f *FakeProvider) DeleteRecord(_ string, id string) (models.RecordInfo, error) {
+	for i, record := range f.Records {
+		if record.Id == id {
+			f.Records = append(f.Records[:i], f.Records[i+1:]...)
+			return record, nil
+		}
+	}
+	return models.RecordInfo{}, nil
+}
+func (f *FakeProvider) SetRecordStatus(string, string, string) (models.RecordInfo, error) {
+	return models.RecordInfo{}, nil
+}
+func (f *FakeProvider) GetRecordInfo(string, string) (models.RecordInfo, error) {
+	return models.RecordInfo{}, nil
+}
@@ -6,7 +6,11 @@ import (
 )
 
 func InitConfig(config string) bool {
-	configPath := "config.toml"
+	return InitConfigFile("config.toml", config)
+}
+
+// InitConfigFile 配置文件不存在时使用示例配置生成，返回是否已生成
+func InitConfigFile(configPath string, config string) bool {
 	if _, err := os.ReadFile(configPath); err != nil {
 		fmt.Println("未找到配置文件，正在为你生成配置文件...")
 		os.WriteFile(configPath, []byte(config), 0644)
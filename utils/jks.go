@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+const (
+	jksMagic          = 0xFEEDFEED
+	jksVersion        = 2
+	jksPrivateKeyTag  = 1
+	jksIntegritySalt  = "Mighty Aphrodite"
+	jksKeyProtectSalt = 20
+)
+
+// jksKeyProtectorOID Sun JKS 私钥保护算法 OID
+var jksKeyProtectorOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+type jksEncryptedPrivateKeyInfo struct {
+	Algo          pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// EncodeJKS 生成只包含一个私钥条目的 Java KeyStore（JKS）文件，私钥与密钥库使用同一密码
+func EncodeJKS(alias string, privateKey any, chain []*x509.Certificate, password string) ([]byte, error) {
+	if password == "" {
+		return nil, errors.New("JKS 密码不能为空")
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("JKS 证书链不能为空")
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	passwordBytes := jksPasswordBytes(password)
+	protectedKey, err := jksProtectKey(keyDER, passwordBytes)
+	if err != nil {
+		return nil, err
+	}
+	encryptedKey, err := asn1.Marshal(jksEncryptedPrivateKeyInfo{
+		Algo:          pkix.AlgorithmIdentifier{Algorithm: jksKeyProtectorOID, Parameters: asn1.NullRawValue},
+		EncryptedData: protectedKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeUint32 := func(v uint32) { _ = binary.Write(&buf, binary.BigEndian, v) }
+	writeUint32(jksMagic)
+	writeUint32(jksVersion)
+	writeUint32(1)
+	// 私钥条目
+	writeUint32(jksPrivateKeyTag)
+	if err = jksWriteUTF(&buf, alias); err != nil {
+		return nil, err
+	}
+	_ = binary.Write(&buf, binary.BigEndian, time.Now().UnixMilli())
+	writeUint32(uint32(len(encryptedKey)))
+	buf.Write(encryptedKey)
+	writeUint32(uint32(len(chain)))
+	for _, cert := range chain {
+		if err = jksWriteUTF(&buf, "X.509"); err != nil {
+			return nil, err
+		}
+		writeUint32(uint32(len(cert.Raw)))
+		buf.Write(cert.Raw)
+	}
+	// 完整性校验：SHA1(密码 + "Mighty Aphrodite" + 内容)
+	digest := sha1.New()
+	digest.Write(passwordBytes)
+	digest.Write([]byte(jksIntegritySalt))
+	digest.Write(buf.Bytes())
+	buf.Write(digest.Sum(nil))
+	return buf.Bytes(), nil
+}
+
+// jksPasswordBytes 将密码转换为 UTF-16BE 字节
+func jksPasswordBytes(password string) []byte {
+	passwordBytes := make([]byte, 0, len(password)*2)
+	for _, r := range password {
+		if r > 0xFFFF {
+			r = 0xFFFD
+		}
+		passwordBytes = append(passwordBytes, byte(r>>8), byte(r))
+	}
+	return passwordBytes
+}
+
+// jksProtectKey 按 Sun KeyProtector 算法加密私钥：salt + 异或后的私钥 + 校验摘要
+func jksProtectKey(plainKey []byte, passwordBytes []byte) ([]byte, error) {
+	salt := make([]byte, jksKeyProtectSalt)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	encrypted := make([]byte, len(plainKey))
+	digest := salt
+	for offset := 0; offset < len(plainKey); offset += sha1.Size {
+		hash := sha1.New()
+		hash.Write(passwordBytes)
+		hash.Write(digest)
+		digest = hash.Sum(nil)
+		for i := 0; i < sha1.Size && offset+i < len(plainKey); i++ {
+			encrypted[offset+i] = plainKey[offset+i] ^ digest[i]
+		}
+	}
+	check := sha1.New()
+	check.Write(passwordBytes)
+	check.Write(plainKey)
+
+	result := make([]byte, 0, len(salt)+len(encrypted)+sha1.Size)
+	result = append(result, salt...)
+	result = append(result, encrypted...)
+	return append(result, check.Sum(nil)...), nil
+}
+
+// jksWriteUTF 按 Java DataOutput.writeUTF 格式写入字符串
+func jksWriteUTF(buf *bytes.Buffer, s string) error {
+	var encoded []byte
+	for _, r := range s {
+		switch {
+		case r >= 0x01 && r <= 0x7F:
+			encoded = append(encoded, byte(r))
+		case r <= 0x7FF:
+			encoded = append(encoded, byte(0xC0|(r>>6)), byte(0x80|(r&0x3F)))
+		default:
+			if r > 0xFFFF {
+				r = 0xFFFD
+			}
+			encoded = append(encoded, byte(0xE0|(r>>12)), byte(0x80|((r>>6)&0x3F)), byte(0x80|(r&0x3F)))
+		}
+	}
+	if len(encoded) > 0xFFFF {
+		return errors.New("JKS 别名过长")
+	}
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(encoded)))
+	buf.Write(encoded)
+	return nil
+}
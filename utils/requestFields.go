@@ -1,11 +1,13 @@
 package utils
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"github.com/alibabacloud-go/tea/tea"
 	"net"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -72,6 +74,22 @@ func HashStringWithCurrentTime(input string) string {
 	return hashString
 }
 
+// RandomToken 生成指定字节长度的随机十六进制字符串
+func RandomToken(length int) string {
+	data := make([]byte, length)
+	if _, err := rand.Read(data); err != nil {
+		// 随机数生成失败时退化为基于时间的哈希
+		return HashStringWithCurrentTime(strconv.Itoa(length))
+	}
+	return hex.EncodeToString(data)
+}
+
+// HashToken 计算 Token 的 SHA-256 哈希，用于 Token 的存储与比较
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
 // HashString 接受一个字符串对其进行哈希处理，返回前5位哈希字符串
 func HashString(input string) string {
 	// 计算 SHA-256 哈希值
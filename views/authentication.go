@@ -1,11 +1,21 @@
 package views
 
 import (
+	"DDNSServer/db"
 	"DDNSServer/models"
 	"DDNSServer/models/requestModel"
+	"DDNSServer/utils"
+	"crypto/subtle"
 	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
 )
 
+// getClientIP 获取客户端真实 IP，只信任 baseConfig.TrustedProxies 中的代理传递的请求头
+func getClientIP(c *gin.Context) string {
+	return models.AccountConfig.BaseConfig.ClientIP(c.RemoteIP(), c.Request.Header)
+}
+
 func ApiAuthentication(c *gin.Context) {
 	accessKeyId := c.GetHeader("AccessKeyId")
 	accessKeySecret := c.GetHeader("AccessKeySecret")
@@ -21,9 +31,76 @@ func ApiAuthentication(c *gin.Context) {
 	}
 }
 
+// PullAuthentication 证书拉取鉴权，Token 通过 Authorization: Bearer 请求头或 token 参数传递
+func PullAuthentication(c *gin.Context) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" {
+		token = c.Query("token")
+	}
+	pullToken, err := db.GetPullTokenForToken(token)
+	if err != nil {
+		requestModel.Unauthorized(c, "token is error")
+		c.Abort()
+		return
+	}
+	c.Set("pullToken", pullToken)
+}
+
+// ChallengeClientAuthentication 外部 ACME 客户端鉴权，支持 acme-dns 的 X-Api-User / X-Api-Key 请求头与 httpreq 的 Basic 认证
+func ChallengeClientAuthentication(c *gin.Context) {
+	username, key := c.GetHeader("X-Api-User"), c.GetHeader("X-Api-Key")
+	if username == "" {
+		username, key, _ = c.Request.BasicAuth()
+	}
+	client, err := db.GetChallengeClientForUsername(username)
+	if err != nil || subtle.ConstantTimeCompare([]byte(client.KeyHash), []byte(utils.HashToken(key))) != 1 {
+		requestModel.Unauthorized(c, "username or key is error")
+		c.Abort()
+		return
+	}
+	if !client.AllowIP(getClientIP(c)) {
+		requestModel.Unauthorized(c, "ip is not allowed")
+		c.Abort()
+		return
+	}
+	c.Set("challengeClient", client)
+}
+
+// DynDNSAuthentication DynDNS2 协议鉴权，使用 Basic 认证，密码可以是快速解析 Token 或 DynDNS2 专用凭据的密码
+func DynDNSAuthentication(c *gin.Context) {
+	username, password, _ := c.Request.BasicAuth()
+	if password != "" {
+		if fastData, err := db.GetFastDataForToken(password); err == nil {
+			c.Set("fastData", fastData)
+			return
+		}
+		client, err := db.GetDDNSClientForUsername(username)
+		if err == nil && subtle.ConstantTimeCompare([]byte(client.KeyHash), []byte(utils.HashToken(password))) == 1 {
+			c.Set("ddnsClient", client)
+			return
+		}
+	}
+	c.Header("WWW-Authenticate", `Basic realm="DomainSprite"`)
+	c.String(http.StatusUnauthorized, "badauth")
+	c.Abort()
+}
+
+// FastPoolSelect 根据路径中的 pool 选择快速解析池，路径中没有 pool 时使用默认解析池
+func FastPoolSelect(c *gin.Context) {
+	pool, ok := models.AccountConfig.FastConfig.GetPool(c.Param("pool"))
+	if !ok {
+		requestModel.NotFound(c, "pool is not exist")
+		c.Abort()
+		return
+	}
+	c.Set("fastPool", pool)
+}
+
+// FastAuthentication 快速解析创建鉴权，校验所选解析池的 AccessSalt
 func FastAuthentication(c *gin.Context) {
 	accessSalt := c.GetHeader("AccessSalt")
-	if accessSalt == "" || accessSalt != models.AccountConfig.FastConfig.AccessSalt {
+	pool := c.MustGet("fastPool").(models.FastConfig)
+	if accessSalt == "" || accessSalt != pool.AccessSalt {
 		requestModel.Forbidden(c, "AccessSalt is error")
 		c.Abort()
 		return
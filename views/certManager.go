@@ -0,0 +1,112 @@
+package views
+
+import (
+	"DDNSServer/db"
+	"DDNSServer/models"
+	"DDNSServer/models/requestModel"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+const certManagerAPIVersion = "webhook.acme.cert-manager.io/v1alpha1"
+
+// certManagerResponse 返回 ChallengePayload，处理结果通过 success 字段表示
+func certManagerResponse(c *gin.Context, uid string, err error) {
+	response := &requestModel.CertManagerChallengeResponse{UID: uid, Success: err == nil}
+	if err != nil {
+		status := &requestModel.CertManagerChallengeStatus{Status: "Failure", Message: err.Error(), Reason: "InternalError", Code: http.StatusInternalServerError}
+		if errors.Is(err, errDomainNotAllowed) {
+			status.Reason, status.Code = "Forbidden", http.StatusForbidden
+		}
+		response.Status = status
+	}
+	c.JSON(http.StatusOK, requestModel.CertManagerChallengePayload{
+		APIVersion: certManagerAPIVersion,
+		Kind:       "ChallengePayload",
+		Response:   response,
+	})
+}
+
+// parseCertManagerRequest 解析 cert-manager 提交的 ChallengePayload，兼容直接提交的 ChallengeRequest
+func parseCertManagerRequest(body []byte) (*requestModel.CertManagerChallengeRequest, error) {
+	var payload requestModel.CertManagerChallengePayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Request != nil {
+		return payload.Request, nil
+	}
+	request := &requestModel.CertManagerChallengeRequest{}
+	if err := json.Unmarshal(body, request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// resolveCertManagerRecord 获取 cert-manager 验证记录写入的位置
+// cnameStrategy: Follow 时 resolvedFQDN 为 CNAME 的目标，按 resolvedZone 在已同步账户的域名中找到写入位置，按 dnsName 校验客户端权限
+func resolveCertManagerRecord(client models.ChallengeClient, request *requestModel.CertManagerChallengeRequest) (challengeRecord, error) {
+	// 未解析 CNAME 时按 dnsName 构造验证域名
+	fqdn := models.CanonicalDNSName(request.ResolvedFQDN)
+	if fqdn == "" {
+		fqdn = "_acme-challenge." + models.CanonicalDNSName(models.ChallengeDomain(request.DNSName))
+	}
+	zoneName := models.CanonicalDNSName(request.ResolvedZone)
+	if zoneName != "" && fqdn != zoneName && !strings.HasSuffix(fqdn, "."+zoneName) {
+		return challengeRecord{}, errors.New("resolvedFQDN 不属于 resolvedZone：" + request.ResolvedZone)
+	}
+	if zoneName == "" {
+		zoneName = fqdn
+	}
+	if request.DNSName != "" && request.Key != "" {
+		if zone, err := db.GetZoneForDomain(zoneName); err == nil && zone.AccountName != "" {
+			return challengeRecord{
+				domain: models.ChallengeDomain(models.CanonicalDNSName(request.DNSName)),
+				zone:   zone.DomainName,
+				fqdn:   fqdn + ".",
+				value:  request.Key,
+			}, nil
+		}
+	}
+	// 不在已同步账户中的域名按验证域名或委托记录处理
+	return resolveChallengeFQDN(client, fqdn, request.Key)
+}
+
+// CertManagerChallengeView cert-manager webhook 验证接口，接收 ChallengePayload 或 ChallengeRequest，
+// 根据 resolvedZone 在域名表中找到所属账户与主域名写入或删除验证记录
+func CertManagerChallengeView(c *gin.Context) {
+	client := c.MustGet("challengeClient").(models.ChallengeClient)
+	body, err := c.GetRawData()
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	request, err := parseCertManagerRequest(body)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	if request.Type != "" && request.Type != "dns-01" {
+		certManagerResponse(c, request.UID, errors.New("不支持的验证类型："+request.Type))
+		return
+	}
+
+	var present bool
+	switch strings.ToLower(request.Action) {
+	case "present":
+		present = true
+	case "cleanup":
+		present = false
+	default:
+		certManagerResponse(c, request.UID, errors.New("不支持的操作："+request.Action))
+		return
+	}
+	record, err := resolveCertManagerRecord(client, request)
+	if err == nil {
+		err = applyChallengeRecord(client, record, present)
+	}
+	certManagerResponse(c, request.UID, err)
+}
@@ -0,0 +1,51 @@
+package views
+
+import (
+	"DDNSServer/models"
+	"DDNSServer/models/requestModel"
+	"testing"
+)
+
+func TestParseCertManagerRequest(t *testing.T) {
+	for name, body := range map[string]string{
+		"ChallengePayload": `{"apiVersion": "webhook.acme.cert-manager.io/v1alpha1", "kind": "ChallengePayload",
+			"request": {"uid": "1", "action": "Present", "dnsName": "www.a.com", "key": "k"}}`,
+		"ChallengeRequest": `{"uid": "1", "action": "Present", "dnsName": "www.a.com", "key": "k"}`,
+	} {
+		request, err := parseCertManagerRequest([]byte(body))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if request.UID != "1" || request.Action != "Present" || request.DNSName != "www.a.com" || request.Key != "k" {
+			t.Errorf("%s: request = %+v", name, request)
+		}
+	}
+	if _, err := parseCertManagerRequest([]byte("{")); err == nil {
+		t.Error("格式错误时应返回错误")
+	}
+}
+
+func TestResolveCertManagerRecord(t *testing.T) {
+	database := openTestDB(t)
+	database.Create(&models.Domains{Id: "1", DomainName: "a.com", DnsFrom: "fake", AccountName: "fake"})
+	database.Create(&models.Domains{Id: "2", DomainName: "b.com", DnsFrom: "fake", AccountName: "fake"})
+	client := models.ChallengeClient{Domains: "a.com"}
+
+	// cnameStrategy: Follow，CNAME 目标位于已同步账户的域名中
+	record, err := resolveCertManagerRecord(client, &requestModel.CertManagerChallengeRequest{
+		DNSName: "*.www.a.com", Key: "k", ResolvedFQDN: "www.acme.b.com.", ResolvedZone: "b.com.",
+	})
+	if err != nil || record.domain != "www.a.com" || record.zone != "b.com" || record.fqdn != "www.acme.b.com." {
+		t.Errorf("resolveCertManagerRecord = %+v, %v", record, err)
+	}
+	// 未解析 CNAME 时按 dnsName 构造验证域名
+	record, err = resolveCertManagerRecord(client, &requestModel.CertManagerChallengeRequest{DNSName: "www.a.com", Key: "k"})
+	if err != nil || record.zone != "a.com" || record.fqdn != "_acme-challenge.www.a.com." {
+		t.Errorf("resolveCertManagerRecord = %+v, %v", record, err)
+	}
+	if _, err = resolveCertManagerRecord(client, &requestModel.CertManagerChallengeRequest{
+		DNSName: "www.a.com", Key: "k", ResolvedFQDN: "www.acme.b.com.", ResolvedZone: "c.com.",
+	}); err == nil {
+		t.Error("resolvedFQDN 不属于 resolvedZone 时应返回错误")
+	}
+}
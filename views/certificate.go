@@ -6,6 +6,7 @@ import (
 	"DDNSServer/models"
 	"DDNSServer/models/requestModel"
 	"DDNSServer/utils"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"log/slog"
@@ -32,12 +33,16 @@ func analyzeDomainForId(domainId string, domainList *[]models.Domains, domainInf
 	return nil
 }
 
+// GetCnameInfoForDomain 获取第三方域名需要添加的CNAME解析，已在数据库中的域名直接使用其所属账户验证，无需解析
 func GetCnameInfoForDomain(domainNameList []string) (cnameInfoList []CnameInfo) {
 	name := "_acme-challenge"
 	for _, domainName := range domainNameList {
+		if zone, err := db.GetZoneForDomain(domainName); err == nil && zone.AccountName != "" {
+			continue
+		}
 		fullDomainName := name + "." + domainName
 		rr := utils.HashString(domainName)
-		value := rr + "." + models.AccountConfig.Certificate.ApplyDomainName
+		value := rr + "." + models.GetChallengeDomainName()
 		cnameInfo := CnameInfo{
 			Name:           name,
 			Type:           "cname",
@@ -50,6 +55,76 @@ func GetCnameInfoForDomain(domainNameList []string) (cnameInfoList []CnameInfo)
 	return cnameInfoList
 }
 
+// getSanList 解析请求中的 SAN 列表，并校验每个 SAN 都属于提交的域名
+func getSanList(sanList string, domainInfoList []models.DomainInfo) ([]string, error) {
+	if sanList == "" {
+		return models.DefaultSanList(domainInfoList), nil
+	}
+	names, err := models.ParseSanList(sanList)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if _, ok := models.FindZone(name, domainInfoList); !ok {
+			return nil, fmt.Errorf("域名 %s 不属于提交的任何域名", name)
+		}
+	}
+	return names, nil
+}
+
+// getDomainInfoListForNames 根据域名名称构造域名信息（第三方申请）
+func getDomainInfoListForNames(domainNameList []string) []models.DomainInfo {
+	var domainInfoList []models.DomainInfo
+	for _, domainName := range domainNameList {
+		domainInfo := models.DomainInfo{}
+		domainInfo.DomainName = strings.ToLower(strings.TrimSpace(domainName))
+		domainInfoList = append(domainInfoList, domainInfo)
+	}
+	return domainInfoList
+}
+
+// createCertificateTask 创建空白证书记录并投递申请任务
+func createCertificateTask(accountName string, domainInfoList []models.DomainInfo, sanList []string, acmeRequest requestModel.AcmeRequest, queue string) (models.Certificate, error) {
+	queue, err := certificate.GetTaskQueue(queue)
+	if err != nil {
+		return models.Certificate{}, err
+	}
+	options, err := models.NewAcmeOptions(acmeRequest.CA, acmeRequest.KeyType, acmeRequest.EABKeyId, acmeRequest.EABHmacKey)
+	if err != nil {
+		return models.Certificate{}, err
+	}
+	if err = certificate.PrepareAcmeAccount(&options); err != nil {
+		return models.Certificate{}, err
+	}
+	// 创建空白证书记录
+	var domainNames []string
+	for _, domainInfo := range domainInfoList {
+		domainNames = append(domainNames, domainInfo.DomainName)
+	}
+	taskId := uuid.New().String()
+	certificateInfo := models.Certificate{
+		State:        "wait",
+		TaskId:       taskId,
+		AccountName:  accountName,
+		ApplyDomains: strings.Join(domainNames, ","),
+		SanList:      strings.Join(sanList, ","),
+		CA:           options.CADirURL,
+		KeyType:      options.KeyType,
+		AcmeEmail:    options.Email,
+	}
+	err = db.DB.Model(&models.Certificate{}).Create(&certificateInfo).Error
+	if err != nil {
+		return certificateInfo, fmt.Errorf("证书记录创建失败：%v", err)
+	}
+	// 创建任务并投递到队列
+	_, err = certificate.EnqueueCertificateCreateTask(accountName, domainInfoList, sanList, certificateInfo, options, taskId, queue)
+	if err != nil {
+		db.DB.Model(&certificateInfo).Update("state", "fail")
+		return certificateInfo, err
+	}
+	return certificateInfo, nil
+}
+
 // CreateCertificateView 申请证书(基于数据库一键申请)
 func CreateCertificateView(c *gin.Context) {
 	// 绑定参数
@@ -80,22 +155,19 @@ func CreateCertificateView(c *gin.Context) {
 		requestModel.BadRequest(c, err.Error())
 		return
 	}
-	provider, err := getProvider(c)
+	sanList, err := getSanList(request.SanList, domainInfoList)
 	if err != nil {
+		requestModel.BadRequest(c, err.Error())
 		return
 	}
-	// 申请证书
-	// 创建空白证书记录
-	taskId := uuid.New().String()
-	certificateInfo := models.Certificate{State: "wait", TaskId: taskId}
-	err = db.DB.Model(&models.Certificate{}).Create(&certificateInfo).Error
+	provider, err := getProvider(c)
 	if err != nil {
-		requestModel.BadRequest(c, "证书记录创建失败："+err.Error())
 		return
 	}
-	// 创建任务
-	_, err = certificate.NewCertificateCreateTask(provider, domainInfoList, certificateInfo, taskId)
+	// 申请证书
+	certificateInfo, err := createCertificateTask(provider.GetAccountInfo().Name, domainInfoList, sanList, request.AcmeRequest, request.Queue)
 	if err != nil {
+		requestModel.BadRequest(c, err.Error())
 		return
 	}
 	for _, domain := range domainList {
@@ -106,7 +178,7 @@ func CreateCertificateView(c *gin.Context) {
 		}
 	}
 	requestModel.Success(c, gin.H{
-		"taskId":      taskId,
+		"taskId":      certificateInfo.TaskId,
 		"certificate": certificateInfo,
 	})
 }
@@ -135,8 +207,13 @@ func GetCertificateViewWithDomainInfo(c *gin.Context) {
 		requestModel.BadRequest(c, err.Error())
 		return
 	}
-	domainNameList := strings.Split(request.DomainNameList, ",")
-	cnameInfoList := GetCnameInfoForDomain(domainNameList)
+	domainInfoList := getDomainInfoListForNames(strings.Split(request.DomainNameList, ","))
+	sanList, err := getSanList(request.SanList, domainInfoList)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	cnameInfoList := GetCnameInfoForDomain(models.GetChallengeDomainList(sanList))
 	requestModel.Success(c, cnameInfoList)
 }
 
@@ -148,8 +225,13 @@ func CreateCertificateViewWithDomainInfo(c *gin.Context) {
 		requestModel.BadRequest(c, err.Error())
 		return
 	}
-	domainNameList := strings.Split(request.DomainNameList, ",")
-	cnameInfoList := GetCnameInfoForDomain(domainNameList)
+	domainInfoList := getDomainInfoListForNames(strings.Split(request.DomainNameList, ","))
+	sanList, err := getSanList(request.SanList, domainInfoList)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	cnameInfoList := GetCnameInfoForDomain(models.GetChallengeDomainList(sanList))
 	// 判断是否所有域名CNAME解析到指定域名
 	var errorCnameInfo []CnameInfo
 	for _, cnameInfo := range cnameInfoList {
@@ -161,34 +243,20 @@ func CreateCertificateViewWithDomainInfo(c *gin.Context) {
 		requestModel.BadRequestWithData(c, "请检查CNAME解析是否正确", errorCnameInfo)
 		return
 	}
-	// 申请证书
-	var domainInfoList []models.DomainInfo
-	for _, domainName := range domainNameList {
-		domainInfo := models.DomainInfo{}
-		domainInfo.DomainName = domainName
-		domainInfoList = append(domainInfoList, domainInfo)
-	}
 	// 获取账号信息
 	provider, err := getProviderForAccountName(models.AccountConfig.Certificate.ApplyAccount)
 	if err != nil {
 		requestModel.BadRequest(c, err.Error())
 		return
 	}
-	// 创建空白证书记录
-	taskId := uuid.New().String()
-	certificateInfo := models.Certificate{State: "wait", TaskId: taskId}
-	err = db.DB.Model(&models.Certificate{}).Create(&certificateInfo).Error
-	if err != nil {
-		requestModel.BadRequest(c, "证书记录创建失败："+err.Error())
-		return
-	}
-	// 创建任务
-	_, err = certificate.NewCertificateCreateTask(provider, domainInfoList, certificateInfo, taskId)
+	// 创建证书申请任务
+	certificateInfo, err := createCertificateTask(provider.GetAccountInfo().Name, domainInfoList, sanList, request.AcmeRequest, request.Queue)
 	if err != nil {
+		requestModel.BadRequest(c, err.Error())
 		return
 	}
 	requestModel.Success(c, gin.H{
-		"taskId":      taskId,
+		"taskId":      certificateInfo.TaskId,
 		"certificate": certificateInfo,
 	})
 }
@@ -229,20 +297,107 @@ func DownloadCertificateViewWithId(c *gin.Context) {
 		requestModel.BadRequest(c, "证书历史读取失败："+err.Error())
 		return
 	}
-	switch request.DownloadType {
-	case "cert":
-		c.File(resource.CertificatePath)
-	case "key":
-		c.File(resource.PrivateKeyPath)
-	case "all":
-		zipPath, err := utils.ZipFolder(resource.SavePath)
-		if err != nil {
-			requestModel.BadRequest(c, "证书压缩失败："+err.Error())
+	exportFile, err := certificate.ExportCertificate(resource, request.DownloadType, request.Password)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename="+exportFile.FileName)
+	c.Data(http.StatusOK, "application/octet-stream", exportFile.Data)
+}
+
+// RenewCertificateView 手动续期证书
+func RenewCertificateView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.CertificateIdRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	certificateDB, err := db.GetCertificateForId(request.CertificateId)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	if certificateDB.State != "success" {
+		requestModel.BadRequest(c, "证书当前状态无法续期："+certificateDB.State)
+		return
+	}
+	taskInfo, err := certificate.EnqueueCertificateRenewTask(certificateDB)
+	if err != nil {
+		requestModel.BadRequest(c, "续期任务投递失败："+err.Error())
+		return
+	}
+	requestModel.Success(c, gin.H{
+		"taskId":      taskInfo.ID,
+		"certificate": certificateDB,
+	})
+}
+
+// RevokeCertificateView 吊销证书
+func RevokeCertificateView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.RevokeCertificateRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	certificateDB, err := db.GetCertificateForId(request.CertificateId)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	if certificateDB.State != "success" {
+		requestModel.BadRequest(c, "证书当前状态无法吊销："+certificateDB.State)
+		return
+	}
+	if err = certificate.RevokeCertificate(certificateDB, request.Reason); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	if err = db.RevokeCertificateInfo(certificateDB.Id); err != nil {
+		requestModel.BadRequest(c, "证书已吊销，但状态更新失败："+err.Error())
+		return
+	}
+	certificateDB.State = "revoked"
+	if request.DeleteFiles {
+		if err = certificate.RemoveCertificateFiles(certificateDB); err != nil {
+			requestModel.BadRequest(c, "证书已吊销，但文件删除失败："+err.Error())
 			return
 		}
-		c.File(zipPath)
 	}
-	return
+	requestModel.Success(c, certificateDB)
+}
+
+// DeleteCertificateView 删除证书记录，不会向 CA 吊销证书
+func DeleteCertificateView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.DeleteCertificateRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	certificateDB, err := db.GetCertificateForId(request.CertificateId)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	if certificateDB.State == "wait" || certificateDB.State == "apply" || certificateDB.State == "renew" {
+		requestModel.BadRequest(c, "证书任务执行中，无法删除："+certificateDB.State)
+		return
+	}
+	// 先删除文件，目录不可删除时保留证书记录
+	if request.DeleteFiles {
+		if err = certificate.RemoveCertificateFiles(certificateDB); err != nil {
+			requestModel.BadRequest(c, err.Error())
+			return
+		}
+	}
+	if err = db.DeleteCertificateInfo(certificateDB.Id); err != nil {
+		requestModel.BadRequest(c, "证书删除失败："+err.Error())
+		return
+	}
+	requestModel.Success(c, "ok")
 }
 
 // GetCertificateTaskInfoByCertificateId 根据证书id查询证书任务信息
@@ -271,7 +426,7 @@ func GetCertificateTaskInfoByCertificateId(c *gin.Context) {
 // GetTaskLog 根据任务id查询任务日志
 func GetTaskLog(c *gin.Context) {
 	// 绑定参数
-	var request requestModel.TaskIdRequest
+	var request requestModel.IdRequest
 	if err := c.Bind(&request); err != nil {
 		requestModel.BadRequest(c, err.Error())
 		return
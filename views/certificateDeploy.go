@@ -0,0 +1,110 @@
+package views
+
+import (
+	"DDNSServer/certificate"
+	"DDNSServer/db"
+	"DDNSServer/models"
+	"DDNSServer/models/requestModel"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// GetCertificateDeployListView 获取证书的部署目标列表
+func GetCertificateDeployListView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.CertificateIdRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	deployList, err := db.GetCertificateDeployList(request.CertificateId)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	requestModel.Success(c, deployList)
+}
+
+// AddCertificateDeployView 添加证书部署目标
+func AddCertificateDeployView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.CertificateDeployRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	if _, err := db.GetCertificateForId(request.CertificateId); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	deploy := models.CertificateDeploy{
+		CertId:     request.CertificateId,
+		Type:       request.Type,
+		CertPath:   request.CertPath,
+		KeyPath:    request.KeyPath,
+		Owner:      request.Owner,
+		FileMode:   request.FileMode,
+		Command:    request.Command,
+		WebhookUrl: request.WebhookUrl,
+		WithKey:    request.WithKey,
+		Enabled:    true,
+		CreateTime: time.Now(),
+	}
+	if err := certificate.ValidateDeploy(deploy); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	if err := db.DB.Model(&deploy).Create(&deploy).Error; err != nil {
+		requestModel.BadRequest(c, "部署目标创建失败："+err.Error())
+		return
+	}
+	requestModel.Success(c, deploy)
+}
+
+// DeleteCertificateDeployView 删除证书部署目标
+func DeleteCertificateDeployView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.IdRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	if err := db.DB.Delete(&models.CertificateDeploy{}, request.Id).Error; err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	requestModel.Success(c, "ok")
+}
+
+// RunCertificateDeployView 立即执行一次部署
+func RunCertificateDeployView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.IdRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	deploy, err := db.GetCertificateDeployForId(request.Id)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	certificateDB, err := db.GetCertificateForId(deploy.CertId)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	certificatePrivate := models.CertificatePrivate{SavePath: certificateDB.SavePath}
+	resource, err := certificatePrivate.LoadResource()
+	if err != nil {
+		requestModel.BadRequest(c, "证书历史读取失败："+err.Error())
+		return
+	}
+	err = certificate.RunDeploy(c, deploy, certificateDB, resource)
+	deploy, _ = db.GetCertificateDeployForId(deploy.Id)
+	if err != nil {
+		requestModel.BadRequestWithData(c, "证书部署失败："+err.Error(), deploy)
+		return
+	}
+	requestModel.Success(c, deploy)
+}
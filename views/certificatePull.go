@@ -0,0 +1,130 @@
+package views
+
+import (
+	"DDNSServer/certificate"
+	"DDNSServer/db"
+	"DDNSServer/models"
+	"DDNSServer/models/requestModel"
+	"DDNSServer/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetPullTokenListView 获取证书拉取 Token 列表
+func GetPullTokenListView(c *gin.Context) {
+	certificateId, _ := strconv.Atoi(c.Query("certificateId"))
+	tokenList, err := db.GetPullTokenList(certificateId)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	requestModel.Success(c, tokenList)
+}
+
+// CreatePullTokenView 创建证书拉取 Token，Token 只在创建时返回一次
+func CreatePullTokenView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.PullTokenRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	if request.CertificateId == 0 && request.Domain == "" {
+		requestModel.BadRequest(c, "证书ID与域名不能同时为空")
+		return
+	}
+	if request.CertificateId != 0 {
+		if _, err := db.GetCertificateForId(request.CertificateId); err != nil {
+			requestModel.BadRequest(c, err.Error())
+			return
+		}
+	}
+	token := utils.RandomToken(32)
+	pullToken := models.CertificatePullToken{
+		Name:        request.Name,
+		TokenHash:   utils.HashToken(token),
+		TokenPrefix: token[:8],
+		CertId:      request.CertificateId,
+		Domain:      strings.ToLower(strings.TrimPrefix(request.Domain, "*.")),
+		CreateTime:  time.Now(),
+	}
+	if err := db.DB.Model(&pullToken).Create(&pullToken).Error; err != nil {
+		requestModel.BadRequest(c, "Token 创建失败："+err.Error())
+		return
+	}
+	requestModel.Success(c, gin.H{
+		"token":     token,
+		"pullToken": pullToken,
+	})
+}
+
+// RevokePullTokenView 吊销证书拉取 Token
+func RevokePullTokenView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.IdRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	result := db.DB.Model(&models.CertificatePullToken{}).Where("id = ?", request.Id).Update("revoked", true)
+	if result.Error != nil {
+		requestModel.BadRequest(c, result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		requestModel.NotFound(c, "Token Not Exist")
+		return
+	}
+	requestModel.Success(c, "ok")
+}
+
+// PullCertificateView 拉取覆盖指定域名的最新证书，支持 If-None-Match 避免重复下载
+func PullCertificateView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.PullCertificateRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	if request.DownloadType == "" {
+		request.DownloadType = "cert"
+	}
+	hostname := strings.ToLower(strings.TrimSuffix(request.Hostname, "."))
+	pullToken := c.MustGet("pullToken").(models.CertificatePullToken)
+	if !pullToken.AllowHostname(hostname) {
+		requestModel.Forbidden(c, "hostname is not allowed")
+		return
+	}
+	certificateDB, err := db.GetLatestCertificateForDomain(hostname, pullToken.CertId)
+	if err != nil {
+		requestModel.NotFound(c, err.Error())
+		return
+	}
+	certificatePrivate := models.CertificatePrivate{SavePath: certificateDB.SavePath}
+	resource, err := certificatePrivate.LoadResource()
+	if err != nil {
+		requestModel.BadRequest(c, "证书历史读取失败："+err.Error())
+		return
+	}
+	db.DB.Model(&pullToken).Update("last_used_time", time.Now())
+
+	// ETag 只与证书内容和格式相关，pfx / jks 每次生成的内容不同也不影响缓存判断
+	etag := `"` + utils.HashToken(request.DownloadType + string(resource.Certificate))[:32] + `"`
+	c.Header("ETag", etag)
+	c.Header("X-Certificate-Id", strconv.Itoa(certificateDB.Id))
+	c.Header("X-Certificate-Not-After", certificateDB.NotAfter.UTC().Format(time.RFC3339))
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	exportFile, err := certificate.ExportCertificate(resource, request.DownloadType, request.Password)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename="+exportFile.FileName)
+	c.Data(http.StatusOK, "application/octet-stream", exportFile.Data)
+}
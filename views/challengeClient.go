@@ -0,0 +1,327 @@
+package views
+
+import (
+	"DDNSServer/certificate"
+	"DDNSServer/db"
+	"DDNSServer/models"
+	"DDNSServer/models/requestModel"
+	"DDNSServer/utils"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/google/uuid"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// acmeDnsTxtPattern acme-dns 的 TXT 值为 43 位 base64url 字符串
+var acmeDnsTxtPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+
+// acmeDnsLock 串行处理 acme-dns 更新，避免同一客户端并发更新时 TXT 值被覆盖
+var acmeDnsLock sync.Mutex
+
+// parseChallengeClientDomains 解析客户端允许的域名列表，通配符域名按其主域名处理
+func parseChallengeClientDomains(domains string) ([]string, error) {
+	sanList, err := models.ParseSanList(domains)
+	if err != nil {
+		return nil, err
+	}
+	var domainList []string
+	exist := map[string]bool{}
+	for _, san := range sanList {
+		domain := models.ChallengeDomain(san)
+		if !exist[domain] {
+			exist[domain] = true
+			domainList = append(domainList, domain)
+		}
+	}
+	if len(domainList) == 0 {
+		return nil, errors.New("域名列表不能为空")
+	}
+	return domainList, nil
+}
+
+// parseAllowFrom 解析允许的来源 IP 段
+func parseAllowFrom(allowFrom string) ([]string, error) {
+	allowList := []string{}
+	for _, cidr := range strings.Split(allowFrom, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(cidr); err != nil && net.ParseIP(cidr) == nil {
+			return nil, errors.New("IP 段格式错误: " + cidr)
+		}
+		allowList = append(allowList, cidr)
+	}
+	return allowList, nil
+}
+
+// RegisterAcmeDnsView 注册外部 ACME 客户端，返回 acme-dns 格式的凭据与需要添加的 CNAME 解析，密码只在注册时返回一次
+func RegisterAcmeDnsView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.ChallengeClientRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	domainList, err := parseChallengeClientDomains(request.Domains)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	allowList, err := parseAllowFrom(request.AllowFrom)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	password := utils.RandomToken(20)
+	client := models.ChallengeClient{
+		Name:       request.Name,
+		Username:   uuid.NewString(),
+		KeyHash:    utils.HashToken(password),
+		Subdomain:  uuid.NewString(),
+		Domains:    strings.Join(domainList, ","),
+		AllowFrom:  strings.Join(allowList, ","),
+		CreateTime: time.Now(),
+	}
+	if err = db.DB.Model(&client).Create(&client).Error; err != nil {
+		requestModel.BadRequest(c, "客户端注册失败："+err.Error())
+		return
+	}
+	fullDomain := client.Subdomain + "." + models.GetChallengeDomainName()
+	var cnameInfoList []CnameInfo
+	for _, domain := range domainList {
+		cnameInfoList = append(cnameInfoList, CnameInfo{
+			Name:           "_acme-challenge",
+			Type:           "cname",
+			Domain:         domain,
+			FullDomainName: "_acme-challenge." + domain,
+			Value:          fullDomain,
+		})
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"username":   client.Username,
+		"password":   password,
+		"fulldomain": fullDomain,
+		"subdomain":  client.Subdomain,
+		"allowfrom":  allowList,
+		"cname":      cnameInfoList,
+	})
+}
+
+// GetChallengeClientListView 获取外部 ACME 客户端列表
+func GetChallengeClientListView(c *gin.Context) {
+	clientList, err := db.GetChallengeClientList()
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	requestModel.Success(c, clientList)
+}
+
+// DeleteChallengeClientView 删除外部 ACME 客户端，并清理其 acme-dns TXT 记录
+func DeleteChallengeClientView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.IdRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	var client models.ChallengeClient
+	if err := db.DB.Model(&client).Where("id = ?", request.Id).First(&client).Error; err != nil {
+		requestModel.NotFound(c, err.Error())
+		return
+	}
+	if client.TxtValues != "" {
+		if provider, err := certificate.NewExternalChallengeProvider(); err == nil {
+			for _, value := range strings.Split(client.TxtValues, ",") {
+				if err = provider.CleanUpDelegate(client.Subdomain, value); err != nil {
+					slog.Warn("清理 acme-dns 记录失败", "subdomain", client.Subdomain, "err", err)
+				}
+			}
+		}
+	}
+	if err := db.DB.Delete(&client).Error; err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	requestModel.Success(c, "ok")
+}
+
+// AcmeDnsUpdateView acme-dns /update，写入客户端子域名的 TXT 记录，与 acme-dns 一致保留最近两个值（主域名与通配符）
+func AcmeDnsUpdateView(c *gin.Context) {
+	client := c.MustGet("challengeClient").(models.ChallengeClient)
+	var request requestModel.AcmeDnsUpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "malformed_json_payload"})
+		return
+	}
+	if request.Subdomain != client.Subdomain {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "forbidden"})
+		return
+	}
+	if !acmeDnsTxtPattern.MatchString(request.Txt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad_txt"})
+		return
+	}
+	provider, err := certificate.NewExternalChallengeProvider()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	acmeDnsLock.Lock()
+	defer acmeDnsLock.Unlock()
+	// 重新读取，获取最新的 TXT 值
+	if client, err = db.GetChallengeClientForUsername(client.Username); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var txtValues []string
+	if client.TxtValues != "" {
+		txtValues = strings.Split(client.TxtValues, ",")
+	}
+	for _, value := range txtValues {
+		if value == request.Txt {
+			c.JSON(http.StatusOK, gin.H{"txt": request.Txt})
+			return
+		}
+	}
+	if err = provider.PresentDelegate(client.Subdomain, request.Txt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	txtValues = append(txtValues, request.Txt)
+	for len(txtValues) > 2 {
+		if err = provider.CleanUpDelegate(client.Subdomain, txtValues[0]); err != nil {
+			slog.Warn("清理 acme-dns 旧记录失败", "subdomain", client.Subdomain, "err", err)
+		}
+		txtValues = txtValues[1:]
+	}
+	db.DB.Model(&client).Updates(map[string]interface{}{
+		"txt_values":     strings.Join(txtValues, ","),
+		"last_used_time": time.Now(),
+	})
+	c.JSON(http.StatusOK, gin.H{"txt": request.Txt})
+}
+
+// AcmeDnsHealthView acme-dns /health
+func AcmeDnsHealthView(c *gin.Context) {
+	c.Status(http.StatusOK)
+}
+
+// HttpReqPresentView lego httpreq /present
+func HttpReqPresentView(c *gin.Context) {
+	handleHttpReq(c, true)
+}
+
+// HttpReqCleanUpView lego httpreq /cleanup
+func HttpReqCleanUpView(c *gin.Context) {
+	handleHttpReq(c, false)
+}
+
+// challengeRecord 外部客户端提交的验证记录，delegateName 不为空时写入第三方验证域名下的委托记录
+type challengeRecord struct {
+	domain       string
+	zone         string // 写入验证记录的主域名，为空时按 domain 查找
+	fqdn         string
+	value        string
+	delegateName string
+}
+
+// zoneDomain 获取用于查找写入账户与主域名的域名
+func (r challengeRecord) zoneDomain() string {
+	if r.zone != "" {
+		return r.zone
+	}
+	return r.domain
+}
+
+// errDomainNotAllowed 客户端无权为该域名写入验证记录
+var errDomainNotAllowed = errors.New("domain is not allowed")
+
+// resolveChallengeFQDN 根据验证记录的完整域名找到对应的域名，客户端跟随 CNAME 后提交的委托记录根据 hash 找到客户端的域名
+func resolveChallengeFQDN(client models.ChallengeClient, fqdn, value string) (challengeRecord, error) {
+	record := challengeRecord{fqdn: models.CanonicalDNSName(fqdn) + ".", value: value}
+	delegateSuffix := "." + models.GetChallengeDomainName() + "."
+	switch {
+	case strings.HasPrefix(record.fqdn, "_acme-challenge."):
+		record.domain = strings.TrimSuffix(strings.TrimPrefix(record.fqdn, "_acme-challenge."), ".")
+	case strings.HasSuffix(record.fqdn, delegateSuffix):
+		record.delegateName = strings.TrimSuffix(record.fqdn, delegateSuffix)
+		for _, allowed := range strings.Split(client.Domains, ",") {
+			if utils.HashString(allowed) == record.delegateName {
+				record.domain = allowed
+			}
+		}
+	}
+	if record.domain == "" || record.value == "" {
+		return record, errors.New("无法识别的验证域名：" + record.fqdn)
+	}
+	return record, nil
+}
+
+// applyChallengeRecord 校验域名属于客户端后写入或删除验证记录，已在账户中的域名写入其所属账户
+func applyChallengeRecord(client models.ChallengeClient, record challengeRecord, present bool) error {
+	if !client.AllowDomain(record.domain) {
+		return fmt.Errorf("%w: %s", errDomainNotAllowed, record.domain)
+	}
+	provider, err := certificate.NewExternalChallengeProvider()
+	if err != nil {
+		return err
+	}
+	switch {
+	case record.delegateName != "" && present:
+		err = provider.PresentDelegate(record.delegateName, record.value)
+	case record.delegateName != "":
+		err = provider.CleanUpDelegate(record.delegateName, record.value)
+	case present:
+		err = provider.PresentRecord(record.zoneDomain(), record.fqdn, record.value)
+	default:
+		err = provider.CleanUpRecord(record.zoneDomain(), record.fqdn, record.value)
+	}
+	if err != nil {
+		return err
+	}
+	db.DB.Model(&client).Update("last_used_time", time.Now())
+	return nil
+}
+
+// handleHttpReq 处理 httpreq 请求，校验域名属于客户端后写入或删除验证记录
+func handleHttpReq(c *gin.Context, present bool) {
+	client := c.MustGet("challengeClient").(models.ChallengeClient)
+	var request requestModel.HttpReqRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+
+	var record challengeRecord
+	var err error
+	if request.KeyAuth != "" {
+		// RAW 模式
+		domain := models.CanonicalDNSName(models.ChallengeDomain(request.Domain))
+		info := dns01.GetChallengeInfo(domain, request.KeyAuth)
+		record = challengeRecord{domain: domain, fqdn: info.FQDN, value: info.Value}
+	} else if record, err = resolveChallengeFQDN(client, request.FQDN, request.Value); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+
+	if err = applyChallengeRecord(client, record, present); err != nil {
+		if errors.Is(err, errDomainNotAllowed) {
+			requestModel.Forbidden(c, err.Error())
+			return
+		}
+		requestModel.Error(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	requestModel.Success(c, "ok")
+}
@@ -7,6 +7,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// newRecordProvider 根据账户创建 RecordProvider，测试时替换为内存中的 Provider
+var newRecordProvider = DDNS.NewBaseProvider
+
 func getProvider(c *gin.Context) (models.RecordProvider, error) {
 	accountName := c.Params.ByName("accountName")
 	provider, err := getProviderForAccountName(accountName)
@@ -23,7 +26,7 @@ func getProviderForAccountName(accountName string) (models.RecordProvider, error
 	if err != nil {
 		return nil, err
 	}
-	provider, err := DDNS.NewBaseProvider(account)
+	provider, err := newRecordProvider(account)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,261 @@
+package views
+
+import (
+	"DDNSServer/db"
+	"DDNSServer/models"
+	"DDNSServer/models/requestModel"
+	"DDNSServer/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// parseHostnames 解析逗号分隔的完整域名列表
+func parseHostnames(hostnames string) []string {
+	var hostnameList []string
+	for _, hostname := range strings.Split(hostnames, ",") {
+		if hostname = models.CanonicalDNSName(hostname); hostname != "" {
+			hostnameList = append(hostnameList, hostname)
+		}
+	}
+	return hostnameList
+}
+
+// CreateDDNSClientView 创建 DynDNS2 专用凭据，域名必须属于已同步的账户，密码只在创建时返回一次
+func CreateDDNSClientView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.DDNSClientRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	hostnameList := parseHostnames(request.Hostnames)
+	if len(hostnameList) == 0 {
+		requestModel.BadRequest(c, "域名列表不能为空")
+		return
+	}
+	for _, hostname := range hostnameList {
+		if zone, err := db.GetZoneForDomain(hostname); err != nil || zone.AccountName == "" {
+			requestModel.BadRequest(c, "域名不属于任何账户："+hostname)
+			return
+		}
+	}
+	if request.Username == "" {
+		request.Username = uuid.NewString()
+	}
+	password := utils.RandomToken(20)
+	client := models.DDNSClient{
+		Name:       request.Name,
+		Username:   request.Username,
+		KeyHash:    utils.HashToken(password),
+		Hostnames:  strings.Join(hostnameList, ","),
+		CreateTime: time.Now(),
+	}
+	if err := db.DB.Model(&client).Create(&client).Error; err != nil {
+		requestModel.BadRequest(c, "凭据创建失败："+err.Error())
+		return
+	}
+	requestModel.Success(c, gin.H{
+		"client":   client,
+		"password": password,
+	})
+}
+
+// GetDDNSClientListView 获取 DynDNS2 专用凭据列表
+func GetDDNSClientListView(c *gin.Context) {
+	clientList, err := db.GetDDNSClientList()
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	requestModel.Success(c, clientList)
+}
+
+// DeleteDDNSClientView 删除 DynDNS2 专用凭据，已有的解析记录保留
+func DeleteDDNSClientView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.IdRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	var client models.DDNSClient
+	if err := db.DB.Model(&client).Where("id = ?", request.Id).First(&client).Error; err != nil {
+		requestModel.NotFound(c, err.Error())
+		return
+	}
+	if err := db.DB.Delete(&client).Error; err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	requestModel.Success(c, "ok")
+}
+
+// NicUpdateView DynDNS2 /nic/update，每个域名返回一行 good / nochg / nohost / notfqdn / 911
+func NicUpdateView(c *gin.Context) {
+	var request requestModel.NicUpdateRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.String(http.StatusOK, "911")
+		return
+	}
+	hostnameList := parseHostnames(request.Hostname)
+	if len(hostnameList) == 0 {
+		c.String(http.StatusOK, "notfqdn")
+		return
+	}
+	addresses, err := parseAddresses(getClientIP(c), request.MyIP, request.MyIPv6)
+	if err != nil {
+		slog.Warn("DynDNS2 更新地址错误", "err", err)
+		c.String(http.StatusOK, "911")
+		return
+	}
+	var lines []string
+	for _, hostname := range hostnameList {
+		lines = append(lines, nicUpdateHost(c, hostname, addresses))
+	}
+	c.String(http.StatusOK, strings.Join(lines, "\n"))
+}
+
+// nicUpdateHost 更新单个域名并返回 DynDNS2 结果
+func nicUpdateHost(c *gin.Context, hostname string, addresses map[string]string) string {
+	if !strings.Contains(hostname, ".") {
+		return "notfqdn"
+	}
+	if value, ok := c.Get("fastData"); ok {
+		return nicUpdateFastRecord(value.(models.FastData).Id, hostname, addresses, newFastSource(c))
+	}
+	client := c.MustGet("ddnsClient").(models.DDNSClient)
+	if !client.AllowHostname(hostname) {
+		return "nohost"
+	}
+	zone, err := db.GetZoneForDomain(hostname)
+	if err != nil || zone.AccountName == "" {
+		return "nohost"
+	}
+	provider, err := getProviderForAccountName(zone.AccountName)
+	if err != nil {
+		slog.Error("DynDNS2 获取账户失败", "hostname", hostname, "err", err)
+		return "911"
+	}
+	changed := false
+	var ipList []string
+	for _, recordType := range fastRecordTypes {
+		ip, ok := addresses[recordType]
+		if !ok {
+			continue
+		}
+		updated, err := setHostRecord(provider, zone, hostname, recordType, ip)
+		if err != nil {
+			slog.Error("DynDNS2 更新记录失败", "hostname", hostname, "type", recordType, "err", err)
+			return "911"
+		}
+		changed = changed || updated
+		ipList = append(ipList, ip)
+	}
+	db.DB.Model(&client).Update("last_used_time", time.Now())
+	return nicResult(changed, ipList)
+}
+
+// nicUpdateFastRecord 使用快速解析 Token 更新其管理的同名主机记录
+func nicUpdateFastRecord(id int, hostname string, addresses map[string]string, source models.FastHistory) string {
+	unlock := lockFastData(id)
+	defer unlock()
+	// 加锁后重新读取，获取最新的记录
+	fastData, err := db.GetFastDataForId(id)
+	if err != nil {
+		return "badauth"
+	}
+	var hosts []models.FastHost
+	for _, host := range fastData.Hosts() {
+		if host.FQDN() == hostname {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return "nohost"
+	}
+	fastData.LastSeenTime = time.Now()
+	changed, err := setFastHosts(&fastData, hosts, addresses, source)
+	if err != nil {
+		slog.Error("DynDNS2 更新快速解析记录失败", "hostname", hostname, "err", err)
+		return "911"
+	}
+	var ipList []string
+	for _, recordType := range fastRecordTypes {
+		if ip, ok := addresses[recordType]; ok {
+			ipList = append(ipList, ip)
+		}
+	}
+	return nicResult(changed, ipList)
+}
+
+// nicResult 拼接 DynDNS2 结果，双栈时地址使用逗号分隔
+func nicResult(changed bool, ipList []string) string {
+	if changed {
+		return "good " + strings.Join(ipList, ",")
+	}
+	return "nochg " + strings.Join(ipList, ",")
+}
+
+// hostRecordName 获取完整域名在主域名下的主机记录，主域名本身为 @
+func hostRecordName(hostname, domainName string) string {
+	if hostname == domainName {
+		return "@"
+	}
+	return strings.TrimSuffix(hostname, "."+domainName)
+}
+
+// findHostRecord 在云服务商中查找域名指定类型的记录，recordType 为空时查找任意类型
+func findHostRecord(provider models.RecordProvider, domainId, domainName, hostname, recordType string) (models.RecordInfo, bool, error) {
+	recordName := hostRecordName(hostname, domainName)
+	// Cloudflare 按完整域名搜索记录
+	searchName := recordName
+	if provider.GetAccountInfo().Type == "Cloudflare" {
+		searchName = hostname
+	}
+	records, err := provider.GetRecordList(models.DNSSearch{
+		DomainId:    domainId,
+		DomainName:  domainName,
+		RRKeyWord:   searchName,
+		TypeKeyWord: recordType,
+	})
+	if err != nil {
+		return models.RecordInfo{}, false, err
+	}
+	for _, record := range records.Records {
+		// 阿里云的主机记录搜索为模糊匹配，需要再次比较
+		if (recordType != "" && record.RecordType != recordType) || (record.RecordName != recordName && models.CanonicalDNSName(record.RecordName) != hostname) {
+			continue
+		}
+		record.DomainId, record.DomainName = domainId, domainName
+		return record, true, nil
+	}
+	return models.RecordInfo{}, false, nil
+}
+
+// setHostRecord 将域名指定类型的记录设置为 ip，不存在时创建，返回是否发生了变更
+func setHostRecord(provider models.RecordProvider, zone models.Domains, hostname, recordType, ip string) (bool, error) {
+	record, found, err := findHostRecord(provider, zone.Id, zone.DomainName, hostname, recordType)
+	if err != nil {
+		return false, err
+	}
+	if found {
+		if record.RecordContent == ip {
+			return false, nil
+		}
+		record.RecordContent = ip
+		_, err = provider.UpdateRecord(record)
+		return err == nil, err
+	}
+	_, err = provider.AddRecord(models.RecordInfo{
+		DomainId:      zone.Id,
+		DomainName:    zone.DomainName,
+		RecordName:    hostRecordName(hostname, zone.DomainName),
+		RecordType:    recordType,
+		RecordContent: ip,
+	})
+	return err == nil, err
+}
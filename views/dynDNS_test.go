@@ -0,0 +1,67 @@
+package views
+
+import (
+	"DDNSServer/models"
+	"DDNSServer/testutil"
+	"DDNSServer/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestNicUpdateView(t *testing.T) {
+	database := openTestDB(t)
+	database.Create(&models.Domains{Id: "1", DomainName: "a.com", DnsFrom: "fake", AccountName: "fake"})
+	database.Create(&models.DDNSClient{Username: "router", KeyHash: utils.HashToken("secret"), Hostnames: "home.a.com"})
+	existing := models.RecordInfo{Id: "1", DomainId: "1", DomainName: "a.com", RecordName: "box1", RecordType: "A", RecordContent: "192.0.2.1"}
+	fastData := models.FastData{Token: "fast-token", AccountName: "fake", DomainId: "1", DomainName: "a.com", RecordName: "box1", RecordInfo: existing}
+	if err := database.Create(&fastData).Error; err != nil {
+		t.Fatal(err)
+	}
+	provider := &testutil.FakeProvider{NextId: 10, Records: []models.RecordInfo{existing}}
+	models.AccountConfig.Accounts = []models.Account{{Name: "fake"}}
+	original := newRecordProvider
+	newRecordProvider = func(models.Account) (models.RecordProvider, error) { return provider, nil }
+	defer func() { newRecordProvider = original }()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/nic/update", DynDNSAuthentication, NicUpdateView)
+	for _, tt := range []struct {
+		name, username, password, query string
+		wantCode                        int
+		want                            string
+	}{
+		{"未提供凭据", "", "", "hostname=home.a.com&myip=192.0.2.10", http.StatusUnauthorized, "badauth"},
+		{"密码错误", "router", "wrong", "hostname=home.a.com&myip=192.0.2.10", http.StatusUnauthorized, "badauth"},
+		{"专用凭据创建记录", "router", "secret", "hostname=home.a.com&myip=192.0.2.10", http.StatusOK, "good 192.0.2.10"},
+		{"地址未变化", "router", "secret", "hostname=home.a.com&myip=192.0.2.10", http.StatusOK, "nochg 192.0.2.10"},
+		{"双栈", "router", "secret", "hostname=home.a.com&myip=192.0.2.10&myipv6=2001:db8::1", http.StatusOK, "good 192.0.2.10,2001:db8::1"},
+		{"专用凭据不允许的域名", "router", "secret", "hostname=box1.a.com&myip=192.0.2.10", http.StatusOK, "nohost"},
+		{"不完整的域名", "router", "secret", "hostname=home&myip=192.0.2.10", http.StatusOK, "notfqdn"},
+		{"缺少域名", "router", "secret", "myip=192.0.2.10", http.StatusOK, "notfqdn"},
+		{"快速解析 Token", "any", "fast-token", "hostname=box1.a.com&myip=192.0.2.2", http.StatusOK, "good 192.0.2.2"},
+		{"Token 未管理的域名", "any", "fast-token", "hostname=home.a.com&myip=192.0.2.2", http.StatusOK, "nohost"},
+		{"多个域名", "router", "secret", "hostname=home.a.com,other.a.com&myip=192.0.2.10", http.StatusOK, "nochg 192.0.2.10\nnohost"},
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/nic/update?"+tt.query, nil)
+		if tt.password != "" {
+			request.SetBasicAuth(tt.username, tt.password)
+		}
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, request)
+		if recorder.Code != tt.wantCode || recorder.Body.String() != tt.want {
+			t.Errorf("%s: %d %q, want %d %q", tt.name, recorder.Code, recorder.Body.String(), tt.wantCode, tt.want)
+		}
+	}
+	var content []string
+	for _, record := range provider.Records {
+		content = append(content, record.RecordName+" "+record.RecordType+" "+record.RecordContent)
+	}
+	want := []string{"box1 A 192.0.2.2", "home A 192.0.2.10", "home AAAA 2001:db8::1"}
+	if !reflect.DeepEqual(content, want) {
+		t.Errorf("records = %v, want %v", content, want)
+	}
+}
@@ -1,134 +1,431 @@
 package views
 
 import (
+	"DDNSServer/db"
 	"DDNSServer/models"
 	"DDNSServer/models/requestModel"
+	"DDNSServer/notify"
 	"DDNSServer/utils"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-const (
-	fastDataFile = "fastData.json"
-)
+// fastLocks 按 Token 串行处理快速解析记录的修改，避免并发请求覆盖彼此的修改，不同 Token 之间互不阻塞
+// 记录名称与编号的唯一性由数据库唯一索引和事务保证
+var fastLocks = &keyedLock{locks: map[string]*keyedMutex{}}
 
-var FastData models.FastDataJson
-var fastDataPath string
+// keyedLock 按 key 加锁，没有使用者的锁会被移除
+type keyedLock struct {
+	mu    sync.Mutex
+	locks map[string]*keyedMutex
+}
 
-func init() {
-	fastDataPath = models.AccountConfig.FastConfig.DataPath + fastDataFile
-	var err error
-	FastData, err = models.GetFastData(fastDataPath)
-	if err != nil {
-		fmt.Println("Error Load FastData:", err)
-		return
+// keyedMutex 单个 key 的锁与等待数量
+type keyedMutex struct {
+	sync.Mutex
+	refs int
+}
+
+// lock 获取 key 对应的锁，返回解锁函数
+func (l *keyedLock) lock(key string) func() {
+	l.mu.Lock()
+	mutex, ok := l.locks[key]
+	if !ok {
+		mutex = &keyedMutex{}
+		l.locks[key] = mutex
+	}
+	mutex.refs++
+	l.mu.Unlock()
+	mutex.Lock()
+	return func() {
+		mutex.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if mutex.refs--; mutex.refs == 0 {
+			delete(l.locks, key)
+		}
 	}
 }
 
-func getDomainRR(provider models.RecordProvider) string {
-	// 拼接出域名
-	id := fmt.Sprintf("%0*d", models.AccountConfig.FastConfig.IdLength, FastData.LastId)
-	domainRR := fmt.Sprintf("%s%s", models.AccountConfig.FastConfig.NameStrata, id)
+// lockFastData 获取快速解析 Token 的锁，返回解锁函数
+func lockFastData(id int) func() {
+	return fastLocks.lock("fast:" + strconv.Itoa(id))
+}
+
+// lockFastHost 获取主域名下主机记录的锁，返回解锁函数，快速解析记录与其他主机记录共用
+func lockFastHost(domainName, recordName string) func() {
+	return fastLocks.lock("host:" + domainName + "|" + recordName)
+}
+
+// isFastRecordNameUsed 判断主机记录在数据库或云服务商中是否已被使用
+func isFastRecordNameUsed(provider models.RecordProvider, zone models.FastZone, domainRR string) (bool, error) {
+	if db.IsFastRecordNameExist(zone.DomainName, domainRR) {
+		return true, nil
+	}
 	// 判断这个解析是否存在
 	list, err := provider.GetRecordList(models.DNSSearch{
-		DomainId:   models.AccountConfig.FastConfig.DomainId,
-		DomainName: models.AccountConfig.FastConfig.DomainName,
+		DomainId:   zone.DomainId,
+		DomainName: zone.DomainName,
 		KeyWord:    domainRR,
 	})
 	if err != nil {
-		fmt.Print("Error GetRecordList:", err)
-		return ""
+		return false, err
+	}
+	return len(list.Records) > 0, nil
+}
+
+// getDomainRR 按解析池的编号分配一个未被使用的主机记录
+func getDomainRR(provider models.RecordProvider, pool models.FastConfig, zone models.FastZone) (string, error) {
+	for {
+		fastId, err := db.NextFastId(pool)
+		if err != nil {
+			return "", err
+		}
+		// 拼接出域名
+		id := fmt.Sprintf("%0*d", pool.IdLength, fastId)
+		domainRR := fmt.Sprintf("%s%s", pool.NameStrata, id)
+		used, err := isFastRecordNameUsed(provider, zone, domainRR)
+		if err != nil {
+			return "", err
+		}
+		if !used {
+			return domainRR, nil
+		}
+	}
+}
+
+// getFastAddresses 获取快速解析的地址，优先使用 ip / ipv4 / ipv6 参数，均为空时使用请求来源 IP
+func getFastAddresses(c *gin.Context) (map[string]string, error) {
+	var request requestModel.FastAddressRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(request.IPv4); request.IPv4 != "" && (ip == nil || ip.To4() == nil) {
+		return nil, errors.New("ipv4 is invalid")
+	}
+	if ip := net.ParseIP(request.IPv6); request.IPv6 != "" && (ip == nil || ip.To4() != nil) {
+		return nil, errors.New("ipv6 is invalid")
+	}
+	return parseAddresses(getClientIP(c), request.IP, request.IPv4, request.IPv6)
+}
+
+// parseAddresses 解析逗号分隔的 IPv4 / IPv6 地址，按记录类型返回，均为空时使用请求来源 IP
+func parseAddresses(remoteIP string, ips ...string) (map[string]string, error) {
+	var ipList []string
+	for _, ip := range strings.Split(strings.Join(ips, ","), ",") {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			ipList = append(ipList, ip)
+		}
+	}
+	if len(ipList) == 0 {
+		ipList = append(ipList, remoteIP)
 	}
-	if len(list.Records) > 0 {
-		// 存在递增继续拼接
-		FastData.LastId++
-		return getDomainRR(provider)
+	addresses := map[string]string{}
+	for _, ip := range ipList {
+		addr := net.ParseIP(ip)
+		if addr == nil {
+			return nil, errors.New("invalid ip: " + ip)
+		}
+		if addr.To4() != nil {
+			addresses["A"] = addr.String()
+		} else {
+			addresses["AAAA"] = addr.String()
+		}
 	}
-	return domainRR
+	return addresses, nil
 }
 
-// IpToDomainRecord 获取IP对应的域名记录
+// IpToDomainRecord 获取IP对应的域名记录，IPv4 创建 A 记录，IPv6 创建 AAAA 记录，双栈时两条记录使用同一个 Token
+// 可以通过 zone 选择解析池中的其他域名，通过 label 指定主机记录
 func IpToDomainRecord(c *gin.Context) {
-	host := c.RemoteIP()
-	// 判断当前ip是否已经拥有记录
-	if fastData, ok := FastData.GetInfoForIp(host); ok {
-		requestModel.Success(c, fastData)
+	var request requestModel.FastCreateRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	pool := c.MustGet("fastPool").(models.FastConfig)
+	zone, ok := pool.GetZone(request.Zone)
+	if !ok {
+		requestModel.BadRequest(c, "zone is not allowed")
+		return
+	}
+	request.Label = strings.ToLower(request.Label)
+	if request.Label != "" && !pool.ValidLabel(request.Label) {
+		requestModel.BadRequest(c, "label is invalid")
 		return
 	}
-	provider, err := getProviderForAccountName(models.AccountConfig.FastConfig.UseAccount)
+	addresses, err := getFastAddresses(c)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	// 同一地址的并发请求串行处理，避免重复创建记录
+	unlock := fastLocks.lock("ip:" + pool.Name + "|" + addresses["A"] + "|" + addresses["AAAA"])
+	defer unlock()
+	// 当前ip已经拥有记录时拒绝创建，Token 只返回一次，客户端应使用已有的 Token 更新，指定主机记录时总是新建
+	for _, recordType := range fastRecordTypes {
+		if request.Label != "" {
+			break
+		}
+		if _, err := db.GetFastDataForIp(pool.Name, addresses[recordType]); err == nil {
+			requestModel.Conflict(c, "address already registered, use your token")
+			return
+		}
+	}
+	provider, err := getProviderForAccountName(zone.UseAccount)
 	if err != nil {
 		requestModel.BadRequest(c, err.Error())
 		return
 	}
 	// 拼接出域名
-	domainRR := getDomainRR(provider)
+	domainRR := request.Label
 	if domainRR == "" {
-		requestModel.BadRequest(c, "Error Get DomainRR")
-		return
+		if domainRR, err = getDomainRR(provider, pool, zone); err != nil {
+			requestModel.BadRequest(c, "Error Get DomainRR: "+err.Error())
+			return
+		}
+	}
+	// 同名主机记录的创建串行处理，避免检查后被其他 Token 的主机记录占用
+	unlockHost := lockFastHost(zone.DomainName, domainRR)
+	defer unlockHost()
+	if request.Label != "" {
+		if used, err := isFastRecordNameUsed(provider, zone, domainRR); err != nil || used {
+			requestModel.BadRequest(c, "label is already in use")
+			return
+		}
 	}
 	// 新增解析
-	recordInfo := models.RecordInfo{
-		DomainId:      models.AccountConfig.FastConfig.DomainId,
-		DomainName:    models.AccountConfig.FastConfig.DomainName,
-		RecordName:    domainRR,
-		RecordType:    "A",
-		RecordContent: host,
+	now := time.Now()
+	fastData := models.FastData{
+		Pool:         pool.Name,
+		AccountName:  zone.UseAccount,
+		DomainId:     zone.DomainId,
+		DomainName:   zone.DomainName,
+		RecordName:   domainRR,
+		InactiveDays: pool.InactiveDays,
+		LastSeenTime: now,
+		CreateTime:   now,
 	}
-	recordInfo, err = provider.AddRecord(recordInfo)
-	if err != nil {
-		requestModel.BadRequest(c, err.Error())
-		return
+	if expireDays := pool.ExpireDays; expireDays > 0 {
+		fastData.ExpireTime = now.AddDate(0, 0, expireDays)
+	}
+	for _, recordType := range fastRecordTypes {
+		if ip := addresses[recordType]; ip != "" {
+			recordInfo, err := addFastRecord(provider, zone, domainRR, recordType, ip, pool.TTL)
+			if err != nil {
+				rollbackFastRecords(provider, &fastData)
+				requestModel.BadRequest(c, err.Error())
+				return
+			}
+			*fastData.Record(recordType) = recordInfo
+		}
 	}
 	// 创建Token
-	Token := utils.HashStringWithCurrentTime(domainRR + recordInfo.DomainName + models.AccountConfig.FastConfig.AccessSalt)
+	fastData.Token = newFastToken()
 	// 保存这条记录
-	fastData := models.FastData{
-		RecordInfo: recordInfo,
-		Token:      Token,
-	}
-	FastData.DataList = append(FastData.DataList, fastData)
-	err = FastData.SaveToJson(fastDataPath)
-	if err != nil {
+	if err = db.CreateFastData(&fastData); err != nil {
+		rollbackFastRecords(provider, &fastData)
 		requestModel.BadRequest(c, err.Error())
 		return
 	}
+	source := newFastSource(c)
+	for _, recordType := range fastRecordTypes {
+		if ip := addresses[recordType]; ip != "" {
+			saveFastHistory(fastData, &fastData, source, recordType, "")
+		}
+	}
 	// 返回记录和Token
 	requestModel.Success(c, fastData)
 }
 
-// UpdateForToken 更新Token对应的记录
+// UpdateForToken 更新Token管理的全部主机记录，Token 还没有对应协议栈的记录时自动创建
+// 返回的 results 中包含每个主机记录的更新结果，部分记录更新失败时返回 400
 func UpdateForToken(c *gin.Context) {
 	token := c.Query("token")
-	host := c.RemoteIP()
-	fastData, exist := FastData.GetInfoForToken(token)
-	if !exist {
+	addresses, err := getFastAddresses(c)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	fastData, err := db.GetFastDataForToken(token)
+	// Token 只能在其所属的解析池中使用
+	if err != nil || fastData.Pool != c.MustGet("fastPool").(models.FastConfig).Name {
 		requestModel.BadRequest(c, "Token Not Exist")
 		return
 	}
-	// 判断当前解析记录是否一致
-	if fastData.RecordInfo.RecordContent == host {
-		requestModel.Success(c, fastData)
+	unlock := lockFastData(fastData.Id)
+	defer unlock()
+	// 加锁后重新读取，获取最新的记录
+	if fastData, err = db.GetFastDataForToken(token); err != nil {
+		requestModel.BadRequest(c, "Token Not Exist")
 		return
-	} else {
-		// 获取快速解析账号
-		provider, err := getProviderForAccountName(models.AccountConfig.FastConfig.UseAccount)
-		if err != nil {
-			requestModel.BadRequest(c, err.Error())
-			return
+	}
+	if _, err = setFastRecords(&fastData, addresses, newFastSource(c)); err != nil {
+		requestModel.BadRequestWithData(c, err.Error(), fastData)
+		return
+	}
+	requestModel.Success(c, fastData)
+}
+
+// newFastSource 记录请求来源，用于快速解析变更历史
+func newFastSource(c *gin.Context) models.FastHistory {
+	return models.FastHistory{SourceIP: getClientIP(c), UserAgent: c.Request.UserAgent()}
+}
+
+// saveFastHistory 保存主机记录的一条变更历史，地址发生变更时发送通知，失败只记录日志
+func saveFastHistory(fastData models.FastData, host models.FastHost, source models.FastHistory, recordType, oldIP string) {
+	history := source
+	history.FastId = fastData.Id
+	history.TokenPrefix = fastData.TokenPrefix
+	history.FQDN = host.FQDN()
+	history.RecordType = recordType
+	history.OldIP = oldIP
+	history.NewIP = host.Record(recordType).RecordContent
+	history.CreateTime = time.Now()
+	if err := db.CreateFastHistory(&history); err != nil {
+		slog.Error("保存快速解析变更历史失败", "fqdn", history.FQDN, "err", err)
+	}
+	if oldIP != "" {
+		notify.Send(notify.ChangeEvent(fastData, history))
+	}
+}
+
+// newFastToken 生成快速解析 Token，数据库中只保存其哈希
+func newFastToken() string {
+	return utils.RandomToken(32)
+}
+
+// fastRecordTypes 快速解析支持的记录类型
+var fastRecordTypes = []string{"A", "AAAA"}
+
+// addFastRecord 在快速解析域名下添加记录，ttl 为 0 时使用云服务商默认值
+func addFastRecord(provider models.RecordProvider, zone models.FastZone, recordName, recordType, ip string, ttl int64) (models.RecordInfo, error) {
+	recordInfo := models.RecordInfo{
+		DomainId:      zone.DomainId,
+		DomainName:    zone.DomainName,
+		RecordName:    recordName,
+		RecordType:    recordType,
+		RecordContent: ip,
+	}
+	if ttl > 0 {
+		recordInfo.Ttl = ttl
+	}
+	return provider.AddRecord(recordInfo)
+}
+
+// deleteFastRecords 删除主机记录在云服务商中的解析，已删除的解析从记录中清除，以便失败后重试
+func deleteFastRecords(provider models.RecordProvider, host models.FastHost) error {
+	var errs []error
+	for _, recordType := range fastRecordTypes {
+		record := host.Record(recordType)
+		if record.Id == "" {
+			continue
 		}
-		// 修改解析
-		fastData.RecordInfo.RecordContent = host
-		fastData.RecordInfo, err = provider.UpdateRecord(fastData.RecordInfo)
-		if err != nil {
-			requestModel.BadRequest(c, err.Error())
-			return
+		if _, err := provider.DeleteRecord(record.DomainName, record.Id); err != nil {
+			errs = append(errs, fmt.Errorf("删除 %s %s 记录失败: %w", host.FQDN(), recordType, err))
+			continue
 		}
-		// 更新记录信息
-		err = FastData.SaveToJson(fastDataPath)
+		*record = models.RecordInfo{}
+	}
+	return errors.Join(errs...)
+}
+
+// rollbackFastRecords 创建失败时删除已添加的解析
+func rollbackFastRecords(provider models.RecordProvider, host models.FastHost) {
+	if err := deleteFastRecords(provider, host); err != nil {
+		slog.Warn("回滚快速解析记录失败", "fqdn", host.FQDN(), "err", err)
+	}
+}
+
+// setFastRecords 更新 Token 管理的全部主机记录并记录本次更新时间，返回是否发生了变更，调用方需持有该 Token 的锁
+func setFastRecords(fastData *models.FastData, addresses map[string]string, source models.FastHistory) (bool, error) {
+	fastData.LastSeenTime = time.Now()
+	return setFastHosts(fastData, fastData.Hosts(), addresses, source)
+}
+
+// setFastHosts 按记录类型更新 Token 的指定主机记录，每个主机记录的结果保存在 fastData.Results 中，调用方需持有该 Token 的锁
+// 先获取全部主机记录的账户，任一账户不可用时不做任何修改；单个主机记录更新失败不影响其他记录，发生变更的记录保存到变更历史中
+func setFastHosts(fastData *models.FastData, hosts []models.FastHost, addresses map[string]string, source models.FastHistory) (bool, error) {
+	providers := map[string]models.RecordProvider{}
+	for _, host := range hosts {
+		accountName := host.Zone().UseAccount
+		if _, ok := providers[accountName]; ok {
+			continue
+		}
+		provider, err := getProviderForAccountName(accountName)
 		if err != nil {
-			requestModel.BadRequest(c, err.Error())
-			return
+			return false, err
 		}
-		requestModel.Success(c, fastData)
+		providers[accountName] = provider
+	}
+	pool, _ := models.AccountConfig.FastConfig.GetPool(fastData.Pool)
+	type fastChange struct {
+		host       models.FastHost
+		recordType string
+		oldIP      string
+	}
+	var changes []fastChange
+	var errs []error
+	fastData.Results = nil
+	for _, host := range hosts {
+		result := models.FastResult{FQDN: host.FQDN(), Status: "nochg"}
+		for _, recordType := range fastRecordTypes {
+			ip := addresses[recordType]
+			if ip == "" {
+				continue
+			}
+			oldIP := host.Record(recordType).RecordContent
+			updated, err := setFastRecord(providers[host.Zone().UseAccount], host, pool.TTL, recordType, ip)
+			if err != nil {
+				result.Status, result.Message = "fail", err.Error()
+				errs = append(errs, fmt.Errorf("%s: %w", result.FQDN, err))
+				break
+			}
+			if updated {
+				result.Status = "good"
+				changes = append(changes, fastChange{host: host, recordType: recordType, oldIP: oldIP})
+			}
+		}
+		fastData.Results = append(fastData.Results, result)
+	}
+	// 已完成的修改同样需要保存
+	if err := db.SaveFastData(fastData); err != nil {
+		return len(changes) > 0, errors.Join(append(errs, err)...)
+	}
+	for _, change := range changes {
+		saveFastHistory(*fastData, change.host, source, change.recordType, change.oldIP)
+	}
+	return len(changes) > 0, errors.Join(errs...)
+}
+
+// setFastRecord 将主机指定类型的记录更新为 ip，记录不存在时创建，记录值一致时不做修改，返回是否发生了变更
+func setFastRecord(provider models.RecordProvider, host models.FastHost, ttl int64, recordType, ip string) (bool, error) {
+	record := host.Record(recordType)
+	// 判断当前解析记录是否一致
+	if record.RecordType != "" && record.RecordContent == ip {
+		return false, nil
+	}
+	var recordInfo models.RecordInfo
+	var err error
+	if record.RecordType == "" {
+		// 新增解析，只管理自己创建的记录，不接管云服务商中已有的同名记录
+		recordInfo, err = addFastRecord(provider, host.Zone(), host.Label(), recordType, ip, ttl)
+	} else {
+		// 修改解析
+		recordInfo = *record
+		recordInfo.RecordContent = ip
+		recordInfo, err = provider.UpdateRecord(recordInfo)
+	}
+	if err != nil {
+		return false, err
 	}
+	*record = recordInfo
+	return true, nil
 }
@@ -0,0 +1,84 @@
+package views
+
+import (
+	"DDNSServer/models"
+	"DDNSServer/testutil"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSetFastRecord(t *testing.T) {
+	provider := &testutil.FakeProvider{NextId: 10}
+	// 不存在时新建，并使用解析池的 TTL
+	wildcard := &models.FastRecord{DomainName: "b.com", RecordName: "*.box1"}
+	if updated, err := setFastRecord(provider, wildcard, 60, "AAAA", "2001:db8::1"); err != nil || !updated {
+		t.Fatalf("setFastRecord = %v, %v", updated, err)
+	}
+	if record := wildcard.RecordInfoV6; record.Id != "11" || record.RecordName != "*.box1" || record.Ttl != 60 {
+		t.Errorf("RecordInfoV6 = %+v", record)
+	}
+	// 已创建的记录直接修改
+	if updated, err := setFastRecord(provider, wildcard, 60, "AAAA", "2001:db8::2"); err != nil || !updated {
+		t.Fatalf("setFastRecord = %v, %v", updated, err)
+	}
+	if len(provider.Records) != 1 || provider.Records[0].RecordContent != "2001:db8::2" {
+		t.Errorf("记录应更新而不是新建: %+v", provider.Records)
+	}
+	if updated, _ := setFastRecord(provider, wildcard, 60, "AAAA", "2001:db8::2"); updated {
+		t.Error("地址一致时不应修改")
+	}
+}
+
+func TestKeyedLock(t *testing.T) {
+	l := &keyedLock{locks: map[string]*keyedMutex{}}
+	unlock := l.lock("a")
+	// 不同 key 互不阻塞
+	l.lock("b")()
+	done := make(chan struct{})
+	go func() {
+		l.lock("a")()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("同一 key 应等待锁释放")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	<-done
+	if len(l.locks) != 0 {
+		t.Errorf("释放后仍保留 %d 个锁", len(l.locks))
+	}
+}
+
+func TestGetFastAddresses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tt := range []struct {
+		name, query string
+		want        map[string]string
+		wantErr     bool
+	}{
+		{"使用请求来源 IP", "", map[string]string{"A": "203.0.113.7"}, false},
+		{"ipv4 参数", "ipv4=192.0.2.1", map[string]string{"A": "192.0.2.1"}, false},
+		{"ipv6 参数", "ipv6=2001:db8::1", map[string]string{"AAAA": "2001:db8::1"}, false},
+		{"双栈", "ipv4=192.0.2.1&ipv6=2001:DB8::1", map[string]string{"A": "192.0.2.1", "AAAA": "2001:db8::1"}, false},
+		{"逗号分隔的 ip 列表", "ip=192.0.2.1,+2001:db8::1", map[string]string{"A": "192.0.2.1", "AAAA": "2001:db8::1"}, false},
+		{"IPv4 映射地址视为 IPv4", "ip=::ffff:192.0.2.1", map[string]string{"A": "192.0.2.1"}, false},
+		{"ipv4 参数中的 IPv6 地址", "ipv4=2001:db8::1", nil, true},
+		{"ipv6 参数中的 IPv4 地址", "ipv6=192.0.2.1", nil, true},
+		{"ipv6 参数中的 IPv4 映射地址", "ipv6=::ffff:192.0.2.1", nil, true},
+		{"无效地址", "ip=192.0.2.1,invalid", nil, true},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		c.Request.RemoteAddr = "203.0.113.7:1234"
+		addresses, err := getFastAddresses(c)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(addresses, tt.want) {
+			t.Errorf("%s: getFastAddresses = %v, %v, want %v", tt.name, addresses, err, tt.want)
+		}
+	}
+}
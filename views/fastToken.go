@@ -0,0 +1,352 @@
+package views
+
+import (
+	"DDNSServer/db"
+	"DDNSServer/models"
+	"DDNSServer/models/requestModel"
+	"DDNSServer/notify"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log/slog"
+	"time"
+)
+
+// defaultReapInterval 默认每小时清理一次失效的快速解析 Token
+const defaultReapInterval = 60
+
+// GetFastTokenListView 获取快速解析 Token 列表，包含当前记录与最近一次更新时间
+func GetFastTokenListView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.FastTokenListRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	if request.Page <= 0 {
+		request.Page = 1
+	}
+	if request.PageSize <= 0 {
+		request.PageSize = 10
+	}
+	fastDataList, total, err := db.GetFastDataList(request.KeyWord, request.Page, request.PageSize)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	requestModel.Success(c, gin.H{
+		"list":     fastDataList,
+		"total":    total,
+		"page":     request.Page,
+		"pageSize": request.PageSize,
+	})
+}
+
+// GetFastHistoryView 获取快速解析变更历史
+func GetFastHistoryView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.FastHistoryListRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	if request.Page <= 0 {
+		request.Page = 1
+	}
+	if request.PageSize <= 0 {
+		request.PageSize = 10
+	}
+	historyList, total, err := db.GetFastHistoryList(request.FastId, request.KeyWord, request.Page, request.PageSize)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	requestModel.Success(c, gin.H{
+		"list":     historyList,
+		"total":    total,
+		"page":     request.Page,
+		"pageSize": request.PageSize,
+	})
+}
+
+// UpdateFastTokenView 修改快速解析 Token 的备注名称、过期时间与失效天数
+func UpdateFastTokenView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.FastTokenUpdateRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	unlock := lockFastData(request.Id)
+	defer unlock()
+	fastData, err := db.GetFastDataForId(request.Id)
+	if err != nil {
+		requestModel.NotFound(c, err.Error())
+		return
+	}
+	fastData.Name = request.Name
+	fastData.ExpireTime = request.ExpireTime
+	fastData.InactiveDays = request.InactiveDays
+	if err = db.SaveFastData(&fastData); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	requestModel.Success(c, fastData)
+}
+
+// RotateFastTokenView 重新生成快速解析 Token，旧 Token 立即失效，解析记录保持不变，新 Token 只返回一次
+func RotateFastTokenView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.IdRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	unlock := lockFastData(request.Id)
+	defer unlock()
+	fastData, err := db.GetFastDataForId(request.Id)
+	if err != nil {
+		requestModel.NotFound(c, err.Error())
+		return
+	}
+	fastData.Token = newFastToken()
+	if err = db.SaveFastData(&fastData); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	requestModel.Success(c, fastData)
+}
+
+// RevokeFastTokenView 吊销快速解析 Token，同时删除其解析记录
+func RevokeFastTokenView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.IdRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	unlock := lockFastData(request.Id)
+	defer unlock()
+	fastData, err := db.GetFastDataForId(request.Id)
+	if err != nil {
+		requestModel.NotFound(c, err.Error())
+		return
+	}
+	if err = removeFastData(fastData); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	requestModel.Success(c, "ok")
+}
+
+// AddFastRecordView 为快速解析 Token 添加其他主机记录，可以位于任意已同步账户的域名下，并立即解析到 Token 当前的地址
+func AddFastRecordView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.FastRecordRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	hostname := models.CanonicalDNSName(request.Hostname)
+	zone, err := db.GetZoneForDomain(hostname)
+	if err != nil || zone.AccountName == "" {
+		requestModel.BadRequest(c, "域名不属于任何账户："+hostname)
+		return
+	}
+	recordName := hostRecordName(hostname, zone.DomainName)
+	provider, err := getProviderForAccountName(zone.AccountName)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	// 同名主机记录的添加串行处理，避免检查后被其他 Token 占用
+	unlockHost := lockFastHost(zone.DomainName, recordName)
+	defer unlockHost()
+	// 云服务商中已有的同名记录不由 DomainSprite 管理，拒绝添加，避免更新时覆盖、吊销时误删
+	_, found, err := findHostRecord(provider, zone.Id, zone.DomainName, hostname, "")
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	if found {
+		requestModel.BadRequest(c, "hostname is already in use")
+		return
+	}
+	unlock := lockFastData(request.Id)
+	defer unlock()
+	fastData, err := db.GetFastDataForId(request.Id)
+	if err != nil {
+		requestModel.NotFound(c, err.Error())
+		return
+	}
+	if db.IsFastRecordNameExist(zone.DomainName, recordName) {
+		requestModel.BadRequest(c, "hostname is already in use")
+		return
+	}
+	record := models.FastRecord{
+		FastId:      fastData.Id,
+		AccountName: zone.AccountName,
+		DomainId:    zone.Id,
+		DomainName:  zone.DomainName,
+		RecordName:  recordName,
+		CreateTime:  time.Now(),
+	}
+	// 检查与写入在同一事务中完成，其他进程并发写入同名主机记录时同样只有一个能成功
+	if err = db.CreateFastRecord(&record); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	fastData.Records = append(fastData.Records, record)
+	addresses := map[string]string{}
+	for _, recordType := range fastRecordTypes {
+		if ip := fastData.Record(recordType).RecordContent; ip != "" {
+			addresses[recordType] = ip
+		}
+	}
+	host := &fastData.Records[len(fastData.Records)-1]
+	// 解析失败时记录仍然保存，客户端下次更新时重试
+	if _, err = setFastHosts(&fastData, []models.FastHost{host}, addresses, newFastSource(c)); err != nil {
+		requestModel.BadRequestWithData(c, err.Error(), fastData)
+		return
+	}
+	requestModel.Success(c, fastData)
+}
+
+// DeleteFastRecordView 删除快速解析 Token 的其他主机记录，同时删除其解析
+func DeleteFastRecordView(c *gin.Context) {
+	// 绑定参数
+	var request requestModel.IdRequest
+	if err := c.Bind(&request); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	record, err := db.GetFastRecordForId(request.Id)
+	if err != nil {
+		requestModel.NotFound(c, err.Error())
+		return
+	}
+	unlock := lockFastData(record.FastId)
+	defer unlock()
+	// 加锁后重新读取，记录可能已被并发删除
+	if record, err = db.GetFastRecordForId(request.Id); err != nil {
+		requestModel.NotFound(c, err.Error())
+		return
+	}
+	provider, err := getProviderForAccountName(record.AccountName)
+	if err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	if err = deleteFastRecords(provider, &record); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	if err = db.DeleteFastRecord(record.Id); err != nil {
+		requestModel.BadRequest(c, err.Error())
+		return
+	}
+	requestModel.Success(c, "ok")
+}
+
+// removeFastData 删除全部主机记录的解析后删除 Token，解析删除失败时保留 Token 以便重试，调用方需持有该 Token 的锁
+func removeFastData(fastData models.FastData) error {
+	var errs []error
+	for _, host := range fastData.Hosts() {
+		provider, err := getProviderForAccountName(host.Zone().UseAccount)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err = deleteFastRecords(provider, host); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		// 保存已删除的解析，重试时跳过
+		if saveErr := db.SaveFastData(&fastData); saveErr != nil {
+			slog.Error("保存快速解析记录失败", "recordName", fastData.RecordName, "err", saveErr)
+		}
+		return err
+	}
+	return db.DeleteFastData(fastData.Id)
+}
+
+// reapFastData 清理已过期或长时间未更新的快速解析 Token 及其解析记录，查询时不加锁，逐个 Token 加锁删除
+func reapFastData() {
+	fastDataList, err := db.GetFastDataWithLifecycle()
+	if err != nil {
+		slog.Error("查询快速解析 Token 失败", "err", err)
+		return
+	}
+	now := time.Now()
+	for _, fastData := range fastDataList {
+		if !fastData.Expired(now) {
+			continue
+		}
+		if err = reapFastToken(fastData.Id, now); err != nil {
+			slog.Error("清理失效的快速解析 Token 失败", "recordName", fastData.RecordName, "err", err)
+			continue
+		}
+	}
+}
+
+// reapFastToken 持有 Token 的锁清理单个失效的 Token，加锁后重新读取，跳过已被删除或已经重新上报的 Token
+func reapFastToken(id int, now time.Time) error {
+	unlock := lockFastData(id)
+	defer unlock()
+	fastData, err := db.GetFastDataForId(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !fastData.Expired(now) {
+		return nil
+	}
+	if err = removeFastData(fastData); err != nil {
+		return err
+	}
+	slog.Info("已清理失效的快速解析 Token", "recordName", fastData.RecordName, "lastSeenTime", fastData.LastSeenTime)
+	return nil
+}
+
+// notifyInactiveFastData 通知超过 InactiveHours 未上报的主机，每次未上报只通知一次
+func notifyInactiveFastData() {
+	inactiveHours := models.AccountConfig.Notify.InactiveHours
+	if inactiveHours <= 0 || !notify.Enabled() {
+		return
+	}
+	now := time.Now()
+	before := now.Add(-time.Duration(inactiveHours) * time.Hour)
+	fastDataList, err := db.GetFastDataInactiveSince(before)
+	if err != nil {
+		slog.Error("查询快速解析记录失败", "err", err)
+		return
+	}
+	for _, fastData := range fastDataList {
+		lastActive := fastData.LastActiveTime()
+		if !lastActive.Before(before) || fastData.NotifiedTime.After(lastActive) {
+			continue
+		}
+		if err = db.SetFastDataNotified(fastData.Id, now); err != nil {
+			slog.Error("更新快速解析通知时间失败", "recordName", fastData.RecordName, "err", err)
+			continue
+		}
+		notify.Send(notify.InactiveEvent(fastData, now))
+	}
+}
+
+// StartFastReaper 定期清理失效的快速解析 Token，并通知长时间未上报的主机
+func StartFastReaper() {
+	interval := models.AccountConfig.FastConfig.ReapInterval
+	if interval <= 0 {
+		interval = defaultReapInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		reapFastData()
+		notifyInactiveFastData()
+	}
+}
@@ -0,0 +1,168 @@
+package views
+
+import (
+	"DDNSServer/models"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// limiterIdleTime 超过该时间没有请求的 IP 限流器会被清理
+const limiterIdleTime = 10 * time.Minute
+
+const (
+	defaultIPv6Prefix = 64    // 默认按 /64 合并 IPv6 地址，同一用户通常分配到整个 /64
+	defaultMaxClients = 10000 // 默认同时记录的客户端数量上限
+)
+
+// ipLimiter 单个 IP 的限流器
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// ipRateLimiter 按客户端 IP 限流
+type ipRateLimiter struct {
+	lock      sync.Mutex
+	limiters  map[string]*ipLimiter
+	limit     rate.Limit
+	burst     int
+	v6Mask    net.IPMask
+	maxSize   int
+	lastPrune time.Time
+}
+
+func newIpRateLimiter(config models.IPEchoConfig) *ipRateLimiter {
+	burst := config.Burst
+	if burst <= 0 {
+		burst = max(1, int(config.RateLimit))
+	}
+	prefix := config.IPv6Prefix
+	if prefix <= 0 || prefix > 128 {
+		prefix = defaultIPv6Prefix
+	}
+	maxSize := config.MaxClients
+	if maxSize <= 0 {
+		maxSize = defaultMaxClients
+	}
+	return &ipRateLimiter{
+		limiters: map[string]*ipLimiter{},
+		limit:    rate.Limit(config.RateLimit),
+		burst:    burst,
+		v6Mask:   net.CIDRMask(prefix, 128),
+		maxSize:  maxSize,
+	}
+}
+
+// key 获取限流使用的键，IPv6 地址按前缀合并，避免轮换同一网段内的地址绕过限流
+func (l *ipRateLimiter) key(ip string) string {
+	addr := net.ParseIP(ip)
+	if addr == nil || addr.To4() != nil {
+		return ip
+	}
+	return addr.Mask(l.v6Mask).String()
+}
+
+// prune 清理空闲的限流器
+func (l *ipRateLimiter) prune(now time.Time) {
+	for key, entry := range l.limiters {
+		if now.Sub(entry.lastSeen) > limiterIdleTime {
+			delete(l.limiters, key)
+		}
+	}
+	l.lastPrune = now
+}
+
+// Allow 判断该 IP 当前是否允许请求，并定期清理空闲的限流器
+// 记录的客户端数量达到上限且无法清理时拒绝新的客户端，已记录的客户端不受影响
+func (l *ipRateLimiter) Allow(ip string, now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if now.Sub(l.lastPrune) > limiterIdleTime {
+		l.prune(now)
+	}
+	key := l.key(ip)
+	entry, ok := l.limiters[key]
+	if !ok {
+		if len(l.limiters) >= l.maxSize {
+			l.prune(now)
+			if len(l.limiters) >= l.maxSize {
+				return false
+			}
+		}
+		entry = &ipLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[key] = entry
+	}
+	entry.lastSeen = now
+	return entry.limiter.AllowN(now, 1)
+}
+
+var (
+	ipEchoLimiter     *ipRateLimiter
+	ipEchoLimiterOnce sync.Once
+)
+
+// IpEchoRateLimit 公网 IP 查询接口限流，未配置 RateLimit 时不限制
+func IpEchoRateLimit(c *gin.Context) {
+	config := models.AccountConfig.IPEcho
+	if config.RateLimit <= 0 {
+		return
+	}
+	ipEchoLimiterOnce.Do(func() {
+		ipEchoLimiter = newIpRateLimiter(config)
+	})
+	if !ipEchoLimiter.Allow(getClientIP(c), time.Now()) {
+		c.Header("Retry-After", "1")
+		c.String(http.StatusTooManyRequests, "too many requests")
+		c.Abort()
+	}
+}
+
+// ipVersion 获取 IP 的协议版本，无效的 IP 返回 0
+func ipVersion(ip string) int {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return 0
+	}
+	if addr.To4() != nil {
+		return 4
+	}
+	return 6
+}
+
+// IpEchoView 以纯文本返回请求来源 IP
+func IpEchoView(c *gin.Context) {
+	c.String(http.StatusOK, getClientIP(c)+"\n")
+}
+
+// IpEchoJsonView 以 JSON 返回请求来源 IP 与协议版本
+func IpEchoJsonView(c *gin.Context) {
+	ip := getClientIP(c)
+	c.JSON(http.StatusOK, gin.H{
+		"ip":      ip,
+		"version": ipVersion(ip),
+	})
+}
+
+// Ipv4EchoView 以纯文本返回请求来源 IPv4 地址，通过 IPv6 访问时返回 404
+func Ipv4EchoView(c *gin.Context) {
+	ip := getClientIP(c)
+	if ipVersion(ip) != 4 {
+		c.String(http.StatusNotFound, "not an ipv4 address\n")
+		return
+	}
+	c.String(http.StatusOK, ip+"\n")
+}
+
+// Ipv6EchoView 以纯文本返回请求来源 IPv6 地址，通过 IPv4 访问时返回 404
+func Ipv6EchoView(c *gin.Context) {
+	ip := getClientIP(c)
+	if ipVersion(ip) != 6 {
+		c.String(http.StatusNotFound, "not an ipv6 address\n")
+		return
+	}
+	c.String(http.StatusOK, ip+"\n")
+}
@@ -0,0 +1,71 @@
+package views
+
+import (
+	"DDNSServer/models"
+	"testing"
+	"time"
+)
+
+func TestIpRateLimiter(t *testing.T) {
+	limiter := newIpRateLimiter(models.IPEchoConfig{RateLimit: 1, Burst: 2})
+	now := time.Now()
+	for i, want := range []bool{true, true, false} {
+		if got := limiter.Allow("192.0.2.1", now); got != want {
+			t.Errorf("第 %d 次请求 Allow = %v, want %v", i+1, got, want)
+		}
+	}
+	if !limiter.Allow("192.0.2.2", now) {
+		t.Error("不同 IP 的限流互不影响")
+	}
+	if !limiter.Allow("192.0.2.1", now.Add(time.Second)) {
+		t.Error("令牌恢复后应允许请求")
+	}
+	// 空闲的限流器被清理
+	limiter.Allow("192.0.2.3", now.Add(2*limiterIdleTime))
+	if len(limiter.limiters) != 1 {
+		t.Errorf("len(limiters) = %d, want 1", len(limiter.limiters))
+	}
+}
+
+func TestIpRateLimiterIPv6Prefix(t *testing.T) {
+	limiter := newIpRateLimiter(models.IPEchoConfig{RateLimit: 1, Burst: 1})
+	now := time.Now()
+	if !limiter.Allow("2001:db8:1:2::1", now) {
+		t.Fatal("首次请求应允许")
+	}
+	if limiter.Allow("2001:db8:1:2::ffff", now) {
+		t.Error("同一 /64 内轮换地址不应绕过限流")
+	}
+	if !limiter.Allow("2001:db8:1:3::1", now) {
+		t.Error("不同 /64 的限流互不影响")
+	}
+
+	limiter = newIpRateLimiter(models.IPEchoConfig{RateLimit: 1, Burst: 1, IPv6Prefix: 48})
+	limiter.Allow("2001:db8:1:2::1", now)
+	if limiter.Allow("2001:db8:1:3::1", now) {
+		t.Error("配置 /48 时同一 /48 内的地址应合并限流")
+	}
+}
+
+func TestIpRateLimiterMaxClients(t *testing.T) {
+	limiter := newIpRateLimiter(models.IPEchoConfig{RateLimit: 1, Burst: 1, MaxClients: 2})
+	now := time.Now()
+	limiter.Allow("192.0.2.1", now)
+	limiter.Allow("192.0.2.2", now)
+	if limiter.Allow("192.0.2.3", now) {
+		t.Error("达到上限时应拒绝新的客户端")
+	}
+	if len(limiter.limiters) != 2 {
+		t.Errorf("len(limiters) = %d, want 2", len(limiter.limiters))
+	}
+	if !limiter.Allow("192.0.2.1", now.Add(time.Second)) {
+		t.Error("已记录的客户端不受上限影响")
+	}
+	if limiter.Allow("192.0.2.3", now.Add(limiterIdleTime/2)) {
+		t.Error("已记录的客户端未空闲时仍应拒绝新的客户端")
+	}
+	// 空闲的限流器被清理后可以接受新的客户端
+	if !limiter.Allow("192.0.2.3", now.Add(limiterIdleTime+2*time.Second)) {
+		t.Error("清理空闲限流器后应接受新的客户端")
+	}
+}
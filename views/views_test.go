@@ -0,0 +1,24 @@
+package views
+
+import (
+	"DDNSServer/db"
+	"DDNSServer/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+)
+
+// openTestDB 使用临时数据库替换 db.DB
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = database.AutoMigrate(&models.Domains{}, &models.ChallengeClient{}, &models.DDNSClient{}, &models.FastData{}, &models.FastRecord{}, &models.FastSequence{}, &models.FastHistory{}); err != nil {
+		t.Fatal(err)
+	}
+	db.DB = database
+	return database
+}